@@ -159,6 +159,7 @@ type FieldConfigurator struct {
 	isCreatedAt bool
 	isUpdatedAt bool
 	isDeletedAt bool
+	isGenerated bool
 }
 
 // func (fc *FieldConfigurator) CanBeNull() *FieldConfigurator {
@@ -196,6 +197,13 @@ func (fc *FieldConfigurator) IsDeletedAt() *FieldConfigurator {
 	return fc
 }
 
+// IsGenerated marks field as filled by database, like a column with a default value, it's left out of inserts
+// and updates and its value is read back after insert if dialect supports RETURNING.
+func (fc *FieldConfigurator) IsGenerated() *FieldConfigurator {
+	fc.isGenerated = true
+	return fc
+}
+
 func (fc *FieldConfigurator) ColumnName(name string) *FieldConfigurator {
 	fc.column = name
 	return fc
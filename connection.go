@@ -1,8 +1,10 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"sync/atomic"
 
 	"github.com/jedib0t/go-pretty/table"
 )
@@ -11,8 +13,11 @@ type connection struct {
 	Name       string
 	Dialect    *Dialect
 	Connection *sql.DB
+	Replicas   []*sql.DB
 	Schemas    map[string]*schema
 	Logger     Logger
+
+	nextReplica uint64
 }
 
 func (c *connection) Schematic() {
@@ -57,20 +62,69 @@ func GetConnection(name string) *connection {
 	return globalConnections[name]
 }
 
-func (c *connection) exec(q string, args ...any) (sql.Result, error) {
+// defaultConnection returns the only registered connection or the one named default.
+func defaultConnection() (*connection, error) {
+	if len(globalConnections) == 1 {
+		for _, c := range globalConnections {
+			return c, nil
+		}
+	}
+	if c, exists := globalConnections["default"]; exists {
+		return c, nil
+	}
+	return nil, fmt.Errorf("no default connection found, use GetConnection(name) when having more than 1 connection registered")
+}
+
+// executor is what runs our generated queries, it's either a connection or a transaction on it.
+type executor interface {
+	exec(ctx context.Context, q string, args ...any) (sql.Result, error)
+	query(ctx context.Context, q string, args ...any) (*sql.Rows, error)
+	queryRow(ctx context.Context, q string, args ...any) *sql.Row
+}
+
+func (c *connection) exec(ctx context.Context, q string, args ...any) (sql.Result, error) {
 	globalLogger.Debugf(q)
 	globalLogger.Debugf("%v", args)
-	return c.Connection.Exec(q, args...)
+	return c.Connection.ExecContext(ctx, q, args...)
 }
 
-func (c *connection) query(q string, args ...any) (*sql.Rows, error) {
+func (c *connection) query(ctx context.Context, q string, args ...any) (*sql.Rows, error) {
 	globalLogger.Debugf(q)
 	globalLogger.Debugf("%v", args)
-	return c.Connection.Query(q, args...)
+	return c.reader(ctx).QueryContext(ctx, q, args...)
 }
 
-func (c *connection) queryRow(q string, args ...any) *sql.Row {
+func (c *connection) queryRow(ctx context.Context, q string, args ...any) *sql.Row {
 	globalLogger.Debugf(q)
 	globalLogger.Debugf("%v", args)
-	return c.Connection.QueryRow(q, args...)
+	return c.reader(ctx).QueryRowContext(ctx, q, args...)
+}
+
+// reader picks the database that read queries should go to, replicas are used in
+// round robin unless there is none or primary is forced using UsePrimary.
+func (c *connection) reader(ctx context.Context) *sql.DB {
+	if len(c.Replicas) == 0 || isPrimaryForced(ctx) {
+		return c.Connection
+	}
+	n := atomic.AddUint64(&c.nextReplica, 1)
+	return c.Replicas[(n-1)%uint64(len(c.Replicas))]
+}
+
+type contextKey int
+
+const (
+	contextKeyUsePrimary contextKey = iota
+	contextKeyWithoutTimestamps
+)
+
+// UsePrimary returns a context that forces every query executed with it to
+// go to the primary database instead of replicas, it's useful when you need
+// to read what you have just written.
+func UsePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKeyUsePrimary, true)
+}
+
+func isPrimaryForced(ctx context.Context) bool {
+	forced, _ := ctx.Value(contextKeyUsePrimary).(bool)
+	return forced
 }
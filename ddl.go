@@ -0,0 +1,324 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+type columnKind int
+
+const (
+	columnKindUnknown columnKind = iota
+	columnKindSmallInt
+	columnKindInt
+	columnKindBigInt
+	columnKindFloat
+	columnKindBool
+	columnKindString
+	columnKindTime
+	columnKindBytes
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	nullTimeType  = reflect.TypeOf(sql.NullTime{})
+	nullKindTypes = map[reflect.Type]columnKind{
+		reflect.TypeOf(sql.NullString{}):  columnKindString,
+		reflect.TypeOf(sql.NullInt64{}):   columnKindBigInt,
+		reflect.TypeOf(sql.NullInt32{}):   columnKindInt,
+		reflect.TypeOf(sql.NullInt16{}):   columnKindSmallInt,
+		reflect.TypeOf(sql.NullByte{}):    columnKindSmallInt,
+		reflect.TypeOf(sql.NullFloat64{}): columnKindFloat,
+		reflect.TypeOf(sql.NullBool{}):    columnKindBool,
+		nullTimeType:                      columnKindTime,
+	}
+)
+
+// kindOf maps a Go type to kind of column that can store it.
+func kindOf(t reflect.Type) columnKind {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if k, exists := nullKindTypes[t]; exists {
+		return k
+	}
+	if t == timeType {
+		return columnKindTime
+	}
+	switch t.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Uint8, reflect.Uint16:
+		return columnKindSmallInt
+	case reflect.Int32, reflect.Uint32:
+		return columnKindInt
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return columnKindBigInt
+	case reflect.Float32, reflect.Float64:
+		return columnKindFloat
+	case reflect.Bool:
+		return columnKindBool
+	case reflect.String:
+		return columnKindString
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return columnKindBytes
+		}
+	}
+	return columnKindUnknown
+}
+
+func isIntegerKind(k columnKind) bool {
+	return k == columnKindSmallInt || k == columnKindInt || k == columnKindBigInt
+}
+
+func mysqlColumnType(t reflect.Type) string {
+	switch kindOf(t) {
+	case columnKindSmallInt:
+		return "SMALLINT"
+	case columnKindInt:
+		return "INT"
+	case columnKindBigInt:
+		return "BIGINT"
+	case columnKindFloat:
+		return "DOUBLE"
+	case columnKindBool:
+		return "BOOLEAN"
+	case columnKindTime:
+		return "DATETIME"
+	case columnKindBytes:
+		return "BLOB"
+	default:
+		return "VARCHAR(255)"
+	}
+}
+
+func postgresColumnType(t reflect.Type) string {
+	switch kindOf(t) {
+	case columnKindSmallInt:
+		return "SMALLINT"
+	case columnKindInt:
+		return "INTEGER"
+	case columnKindBigInt:
+		return "BIGINT"
+	case columnKindFloat:
+		return "DOUBLE PRECISION"
+	case columnKindBool:
+		return "BOOLEAN"
+	case columnKindTime:
+		return "TIMESTAMP"
+	case columnKindBytes:
+		return "BYTEA"
+	default:
+		return "TEXT"
+	}
+}
+
+func sqliteColumnType(t reflect.Type) string {
+	switch kindOf(t) {
+	case columnKindSmallInt, columnKindInt, columnKindBigInt:
+		return "INTEGER"
+	case columnKindFloat:
+		return "REAL"
+	case columnKindBool:
+		return "BOOLEAN"
+	case columnKindTime:
+		return "TIMESTAMP"
+	case columnKindBytes:
+		return "BLOB"
+	default:
+		return "TEXT"
+	}
+}
+
+func mysqlAutoIncrementColumn(t reflect.Type) string {
+	return mysqlColumnType(t) + " NOT NULL AUTO_INCREMENT PRIMARY KEY"
+}
+
+func postgresAutoIncrementColumn(t reflect.Type) string {
+	switch kindOf(t) {
+	case columnKindSmallInt:
+		return "SMALLSERIAL PRIMARY KEY"
+	case columnKindInt:
+		return "SERIAL PRIMARY KEY"
+	default:
+		return "BIGSERIAL PRIMARY KEY"
+	}
+}
+
+func sqliteAutoIncrementColumn(_ reflect.Type) string {
+	return "INTEGER PRIMARY KEY AUTOINCREMENT"
+}
+
+func mysqlModifyColumn(table string, f *field) string {
+	def := fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s", mysqlQuoteIdentifier(table), mysqlQuoteIdentifier(f.Name), mysqlColumnType(f.Type))
+	if !f.Nullable {
+		def += " NOT NULL"
+	}
+	return def
+}
+
+func postgresModifyColumn(table string, f *field) string {
+	t := postgresColumnType(f.Type)
+	column := standardQuoteIdentifier(f.Name)
+	return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s::%s", standardQuoteIdentifier(table), column, t, column, t)
+}
+
+// columnDefinition creates definition of given field to be used in CREATE TABLE and ALTER TABLE statements.
+func columnDefinition(dialect *Dialect, f *field) string {
+	name := quoteIdentifier(dialect, f.Name)
+	if f.IsPK && isIntegerKind(kindOf(f.Type)) {
+		return fmt.Sprintf("%s %s", name, dialect.AutoIncrementColumn(f.Type))
+	}
+	def := fmt.Sprintf("%s %s", name, dialect.ColumnType(f.Type))
+	if f.IsPK {
+		return def + " NOT NULL PRIMARY KEY"
+	}
+	if !f.Nullable {
+		def += " NOT NULL"
+	}
+	if f.Default != nil {
+		def += fmt.Sprintf(" DEFAULT %v", f.Default)
+	}
+	return def
+}
+
+// foreignKeys returns foreign key constraints of the schema based on its BelongsTo relations,
+// relations that their foreign key is not a field of the schema are ignored.
+func (s *schema) foreignKeys(dialect *Dialect) []string {
+	var fks []string
+	for _, rel := range s.relations {
+		c, isBelongsTo := rel.(BelongsToConfig)
+		if !isBelongsTo {
+			continue
+		}
+		for _, f := range s.fields {
+			if f.Name == c.LocalForeignKey && !f.Virtual {
+				fks = append(fks, fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s(%s)", quoteIdentifier(dialect, c.LocalForeignKey), quoteIdentifier(dialect, c.OwnerTable), quoteIdentifier(dialect, c.ForeignColumnName)))
+			}
+		}
+	}
+	sort.Strings(fks)
+	return fks
+}
+
+// indexes returns indexes of the schema based on index and unique keys of fields tags, fields
+// that use the same index name make a multi column index in order of fields.
+func (s *schema) indexes() []*Index {
+	var indexes []*Index
+	byName := map[string]*Index{}
+	add := func(name string, column string, unique bool) {
+		if name == "true" {
+			suffix := "index"
+			if unique {
+				suffix = "unique"
+			}
+			name = fmt.Sprintf("%s_%s_%s", s.Table, column, suffix)
+		}
+		idx, exists := byName[name]
+		if !exists {
+			idx = &Index{Name: name, Unique: unique}
+			byName[name] = idx
+			indexes = append(indexes, idx)
+		}
+		idx.Columns = append(idx.Columns, column)
+	}
+	for _, f := range s.fields {
+		if f.Virtual {
+			continue
+		}
+		if f.Index != "" {
+			add(f.Index, f.Name, false)
+		}
+		if f.UniqueIndex != "" {
+			add(f.UniqueIndex, f.Name, true)
+		}
+	}
+	return indexes
+}
+
+func createIndexSQL(dialect *Dialect, table string, idx *Index) string {
+	kind := "INDEX"
+	if idx.Unique {
+		kind = "UNIQUE INDEX"
+	}
+	return fmt.Sprintf("CREATE %s %s ON %s (%s)", kind, quoteIdentifier(dialect, idx.Name), quoteIdentifier(dialect, table), strings.Join(quoteIdentifiers(dialect, idx.Columns), ", "))
+}
+
+func createTableSQL(dialect *Dialect, s *schema) string {
+	var defs []string
+	for _, f := range s.fields {
+		if f.Virtual {
+			continue
+		}
+		defs = append(defs, columnDefinition(dialect, f))
+	}
+	defs = append(defs, s.foreignKeys(dialect)...)
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", quoteIdentifier(dialect, s.Table), strings.Join(defs, ", "))
+}
+
+// CreateTableSQL generates CREATE TABLE statement of type parameter entity using dialect of its connection.
+func CreateTableSQL[E Entity]() (string, error) {
+	s := getSchemaFor(*new(E))
+	if s.getDialect().ColumnType == nil || s.getDialect().AutoIncrementColumn == nil {
+		return "", fmt.Errorf("dialect %s does not support generating DDL", s.getDialect().DriverName)
+	}
+	return createTableSQL(s.getDialect(), s), nil
+}
+
+// CreateTables creates tables of given entities in their connections if they don't exist,
+// tables are created in an order that owners in BelongsTo relations are created first.
+func CreateTables(entities ...Entity) error {
+	return CreateTablesContext(context.Background(), entities...)
+}
+
+// CreateTablesContext is like CreateTables but uses given context for executing queries.
+func CreateTablesContext(ctx context.Context, entities ...Entity) error {
+	for _, s := range sortByDependency(entities) {
+		dialect := s.getDialect()
+		if dialect.ColumnType == nil || dialect.AutoIncrementColumn == nil {
+			return fmt.Errorf("dialect %s does not support generating DDL", dialect.DriverName)
+		}
+		if _, err := s.getConnection().exec(ctx, createTableSQL(dialect, s)); err != nil {
+			return fmt.Errorf("creating table %s: %w", s.Table, err)
+		}
+	}
+	return nil
+}
+
+// sortByDependency returns schemas of given entities so that owner tables of BelongsTo
+// relations come before their properties.
+func sortByDependency(entities []Entity) []*schema {
+	byTable := map[string]*schema{}
+	var tables []string
+	for _, e := range entities {
+		s := getSchemaFor(e)
+		if _, exists := byTable[s.Table]; !exists {
+			tables = append(tables, s.Table)
+		}
+		byTable[s.Table] = s
+	}
+	visited := map[string]bool{}
+	var sorted []*schema
+	var visit func(table string)
+	visit = func(table string) {
+		s, exists := byTable[table]
+		if !exists || visited[table] {
+			return
+		}
+		visited[table] = true
+		for _, rel := range s.relations {
+			if c, isBelongsTo := rel.(BelongsToConfig); isBelongsTo {
+				visit(c.OwnerTable)
+			}
+		}
+		sorted = append(sorted, s)
+	}
+	for _, table := range tables {
+		visit(table)
+	}
+	return sorted
+}
@@ -0,0 +1,93 @@
+package orm
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Invoice struct {
+	ID     int64
+	UserID int64
+	Amount float64
+	Paid   bool
+	Note   sql.NullString
+	Status string `orm:"default='draft'"`
+	Timestamps
+}
+
+func (i Invoice) ConfigureEntity(e *EntityConfigurator) {
+	e.Table("invoices").BelongsTo(User{}, BelongsToConfig{})
+}
+
+func TestCreateTableSQL(t *testing.T) {
+	s := schemaOfHeavyReflectionStuff(&Invoice{})
+	t.Run("mysql", func(t *testing.T) {
+		assert.Equal(t,
+			"CREATE TABLE IF NOT EXISTS `invoices` (`id` BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY, `user_id` BIGINT NOT NULL, `amount` DOUBLE NOT NULL, `paid` BOOLEAN NOT NULL, `note` VARCHAR(255), `status` VARCHAR(255) NOT NULL DEFAULT 'draft', `created_at` DATETIME, `updated_at` DATETIME, `deleted_at` DATETIME, FOREIGN KEY (`user_id`) REFERENCES `users`(`id`))",
+			createTableSQL(Dialects.MySQL, s))
+	})
+	t.Run("postgres", func(t *testing.T) {
+		assert.Equal(t,
+			`CREATE TABLE IF NOT EXISTS "invoices" ("id" BIGSERIAL PRIMARY KEY, "user_id" BIGINT NOT NULL, "amount" DOUBLE PRECISION NOT NULL, "paid" BOOLEAN NOT NULL, "note" TEXT, "status" TEXT NOT NULL DEFAULT 'draft', "created_at" TIMESTAMP, "updated_at" TIMESTAMP, "deleted_at" TIMESTAMP, FOREIGN KEY ("user_id") REFERENCES "users"("id"))`,
+			createTableSQL(Dialects.PostgreSQL, s))
+	})
+	t.Run("sqlite3", func(t *testing.T) {
+		assert.Equal(t,
+			`CREATE TABLE IF NOT EXISTS "invoices" ("id" INTEGER PRIMARY KEY AUTOINCREMENT, "user_id" INTEGER NOT NULL, "amount" REAL NOT NULL, "paid" BOOLEAN NOT NULL, "note" TEXT, "status" TEXT NOT NULL DEFAULT 'draft', "created_at" TIMESTAMP, "updated_at" TIMESTAMP, "deleted_at" TIMESTAMP, FOREIGN KEY ("user_id") REFERENCES "users"("id"))`,
+			createTableSQL(Dialects.SQLite3, s))
+	})
+}
+
+func TestModifyColumn(t *testing.T) {
+	f := &field{Name: "amount", Type: reflect.TypeOf(float64(0))}
+	assert.Equal(t, "ALTER TABLE `invoices` MODIFY COLUMN `amount` DOUBLE NOT NULL", Dialects.MySQL.ModifyColumn("invoices", f))
+	assert.Equal(t, `ALTER TABLE "invoices" ALTER COLUMN "amount" TYPE DOUBLE PRECISION USING "amount"::DOUBLE PRECISION`, Dialects.PostgreSQL.ModifyColumn("invoices", f))
+	assert.Nil(t, Dialects.SQLite3.ModifyColumn)
+}
+
+type migratedLog struct {
+	ID      int64
+	Message string
+}
+
+func (l migratedLog) ConfigureEntity(e *EntityConfigurator) {
+	e.Table("logs").Connection("first")
+}
+
+type migratedEvent struct {
+	ID    int64
+	Count int64
+}
+
+func (e migratedEvent) ConfigureEntity(c *EntityConfigurator) {
+	c.Table("events").Connection("second")
+}
+
+func TestAutoMigrateChecksEveryPlanFirst(t *testing.T) {
+	open := func(ddl ...string) *sql.DB {
+		db, err := sql.Open("sqlite3", ":memory:")
+		assert.NoError(t, err)
+		db.SetMaxOpenConns(1)
+		for _, q := range ddl {
+			_, err = db.Exec(q)
+			assert.NoError(t, err)
+		}
+		return db
+	}
+	defer func() {
+		delete(globalConnections, "first")
+		delete(globalConnections, "second")
+	}()
+	assert.NoError(t, SetupConnection(ConnectionConfig{Name: "first", DB: open(), Dialect: Dialects.SQLite3, Entities: []Entity{&migratedLog{}}}))
+	// sqlite cannot change type of columns so plan of second connection has a warning.
+	assert.NoError(t, SetupConnection(ConnectionConfig{Name: "second", DB: open(`CREATE TABLE events (id INTEGER PRIMARY KEY, count TEXT)`), Dialect: Dialects.SQLite3, Entities: []Entity{&migratedEvent{}}}))
+
+	_, err := AutoMigrateWithConfig(MigrateConfig{AllowDestructive: true}, &migratedLog{}, &migratedEvent{})
+	assert.Error(t, err)
+	tables, err := GetConnection("first").Tables()
+	assert.NoError(t, err)
+	assert.Empty(t, tables)
+}
@@ -1,6 +1,14 @@
 package orm
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
 
 type Dialect struct {
 	DriverName                  string
@@ -8,8 +16,50 @@ type Dialect struct {
 	IncludeIndexInPlaceholder   bool
 	AddTableNameInSelectColumns bool
 	PlaceHolderGenerator        func(n int) []string
-	ListTables                  func(db *sql.DB) ([]string, error)
-	ListColumns                 func(db *sql.DB, table string) ([]*field, error)
+	ListTables                  func(ctx context.Context, db *sql.DB) ([]string, error)
+	ListColumns                 func(ctx context.Context, db *sql.DB, table string) ([]*Column, error)
+	ListIndexes                 func(ctx context.Context, db *sql.DB, table string) ([]*Index, error)
+	Savepoint                   func(name string) string
+	RollbackToSavepoint         func(name string) string
+	ReleaseSavepoint            func(name string) string
+	// QuoteIdentifier quotes a single table or column name so it's safe to use even if it's a reserved word.
+	QuoteIdentifier func(name string) string
+	// SupportsILike is true when database has ILIKE operator for case-insensitive LIKE,
+	// otherwise both sides of LIKE are lowered.
+	SupportsILike bool
+	// TransactionalDDL is true when schema changes can be done inside a transaction and rolled back.
+	TransactionalDDL bool
+	// SupportsRowLocking is true when database can lock selected rows using FOR UPDATE and FOR SHARE,
+	// locking clauses of queries are left out on other databases.
+	SupportsRowLocking bool
+	// SupportsReturning is true when inserts can return generated primary keys using RETURNING.
+	SupportsReturning bool
+	// ReturningInOrder is true when RETURNING reports rows of a multi-row insert in order of its values, otherwise
+	// returned rows are matched to entities by their integer primary key, which is assigned in insert order.
+	ReturningInOrder bool
+	// SupportsLastInsertID is true when driver reports ID of inserted rows using LastInsertId of sql.Result.
+	SupportsLastInsertID bool
+	// FirstInsertID is true when LastInsertId of a multi-row insert is ID of its first row instead of its last row.
+	FirstInsertID bool
+	// ConsecutiveInsertIDs is true when rows of a multi-row insert always get consecutive IDs so they can be calculated
+	// from LastInsertId, otherwise entities with auto incremented primary key are inserted one row per query.
+	ConsecutiveInsertIDs bool
+	// MaxPlaceholders is the maximum number of placeholders a single query can have, bulk inserts are split into
+	// chunks that fit in it, zero means no limit.
+	MaxPlaceholders int
+	// Detect returns a copy of d with capabilities that depend on version of the database set,
+	// it's called when a connection is set up.
+	Detect func(ctx context.Context, db *sql.DB, d *Dialect) (*Dialect, error)
+	// ColumnType returns SQL type of column that stores values of given Go type.
+	ColumnType func(t reflect.Type) string
+	// AutoIncrementColumn returns column definition of an auto incremented primary key of given Go type.
+	AutoIncrementColumn func(t reflect.Type) string
+	// ModifyColumn returns statement that changes type of a column to what given field needs,
+	// it's nil when database cannot change type of columns.
+	ModifyColumn func(table string, f *field) string
+	// UpsertClause returns clause that follows an insert to update columns in update with inserted values when a row
+	// conflicts with an existing one on target columns, empty update means conflicting rows are left as they are.
+	UpsertClause func(target []string, update []string) string
 }
 
 var Dialects = &struct {
@@ -23,6 +73,23 @@ var Dialects = &struct {
 		IncludeIndexInPlaceholder:   false,
 		AddTableNameInSelectColumns: true,
 		PlaceHolderGenerator:        mySQLPlaceHolder,
+		QuoteIdentifier:             mysqlQuoteIdentifier,
+		Savepoint:                   standardSavepoint,
+		RollbackToSavepoint:         standardRollbackToSavepoint,
+		ReleaseSavepoint:            standardReleaseSavepoint,
+		TransactionalDDL:            false,
+		SupportsRowLocking:          true,
+		SupportsLastInsertID:        true,
+		FirstInsertID:               true,
+		MaxPlaceholders:             65535,
+		Detect:                      mysqlDetect,
+		ColumnType:                  mysqlColumnType,
+		ListTables:                  mysqlListTables,
+		ListColumns:                 mysqlListColumns,
+		ListIndexes:                 mysqlListIndexes,
+		AutoIncrementColumn:         mysqlAutoIncrementColumn,
+		ModifyColumn:                mysqlModifyColumn,
+		UpsertClause:                mysqlUpsertClause,
 	},
 	PostgreSQL: &Dialect{
 		DriverName:                  "postgres",
@@ -30,6 +97,23 @@ var Dialects = &struct {
 		IncludeIndexInPlaceholder:   true,
 		AddTableNameInSelectColumns: true,
 		PlaceHolderGenerator:        postgresPlaceholder,
+		QuoteIdentifier:             standardQuoteIdentifier,
+		Savepoint:                   standardSavepoint,
+		RollbackToSavepoint:         standardRollbackToSavepoint,
+		ReleaseSavepoint:            standardReleaseSavepoint,
+		SupportsILike:               true,
+		TransactionalDDL:            true,
+		SupportsRowLocking:          true,
+		SupportsReturning:           true,
+		ReturningInOrder:            true,
+		MaxPlaceholders:             65535,
+		ColumnType:                  postgresColumnType,
+		ListTables:                  postgresListTables,
+		ListColumns:                 postgresListColumns,
+		ListIndexes:                 postgresListIndexes,
+		AutoIncrementColumn:         postgresAutoIncrementColumn,
+		ModifyColumn:                postgresModifyColumn,
+		UpsertClause:                standardUpsertClause,
 	},
 	SQLite3: &Dialect{
 		DriverName:                  "sqlite3",
@@ -37,5 +121,146 @@ var Dialects = &struct {
 		IncludeIndexInPlaceholder:   false,
 		AddTableNameInSelectColumns: false,
 		PlaceHolderGenerator:        mySQLPlaceHolder,
+		QuoteIdentifier:             standardQuoteIdentifier,
+		Savepoint:                   standardSavepoint,
+		RollbackToSavepoint:         standardRollbackToSavepoint,
+		ReleaseSavepoint:            standardReleaseSavepoint,
+		TransactionalDDL:            true,
+		SupportsLastInsertID:        true,
+		ConsecutiveInsertIDs:        true,
+		MaxPlaceholders:             999,
+		Detect:                      sqliteDetect,
+		ColumnType:                  sqliteColumnType,
+		ListTables:                  sqliteListTables,
+		ListColumns:                 sqliteListColumns,
+		ListIndexes:                 sqliteListIndexes,
+		AutoIncrementColumn:         sqliteAutoIncrementColumn,
+		UpsertClause:                standardUpsertClause,
 	},
 }
+
+// mysqlDetect trusts IDs of multi-row inserts to be consecutive only if auto increment step is one and auto increment
+// lock mode is not interleaved, in which concurrent inserts can take IDs in between rows of an insert. When settings
+// cannot be read, for example on servers without InnoDB, IDs are not trusted and entities are inserted one row at a time.
+func mysqlDetect(ctx context.Context, db *sql.DB, d *Dialect) (*Dialect, error) {
+	detected := *d
+	detected.ConsecutiveInsertIDs = false
+	var increment, lockMode int
+	if err := db.QueryRowContext(ctx, "SELECT @@auto_increment_increment, @@innodb_autoinc_lock_mode").Scan(&increment, &lockMode); err != nil {
+		return &detected, nil
+	}
+	detected.ConsecutiveInsertIDs = increment == 1 && lockMode != 2
+	return &detected, nil
+}
+
+// sqliteDetect enables RETURNING since SQLite 3.35, rows it returns come in arbitrary order.
+func sqliteDetect(ctx context.Context, db *sql.DB, d *Dialect) (*Dialect, error) {
+	var version string
+	if err := db.QueryRowContext(ctx, "SELECT sqlite_version()").Scan(&version); err != nil {
+		return nil, fmt.Errorf("detecting sqlite version: %w", err)
+	}
+	detected := *d
+	detected.SupportsReturning = versionAtLeast(version, 3, 35)
+	return &detected, nil
+}
+
+// versionAtLeast reports whether a dotted version like 3.39.2 is at least major.minor.
+func versionAtLeast(version string, major int, minor int) bool {
+	var v [2]int
+	for i, part := range strings.SplitN(version, ".", 3) {
+		if i == 2 {
+			break
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return false
+		}
+		v[i] = n
+	}
+	return v[0] > major || (v[0] == major && v[1] >= minor)
+}
+
+func standardSavepoint(name string) string {
+	return fmt.Sprintf("SAVEPOINT %s", name)
+}
+
+func standardRollbackToSavepoint(name string) string {
+	return fmt.Sprintf("ROLLBACK TO SAVEPOINT %s", name)
+}
+
+func standardReleaseSavepoint(name string) string {
+	return fmt.Sprintf("RELEASE SAVEPOINT %s", name)
+}
+
+func mysqlQuoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func standardQuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// mysqlUpsertClause ignores target since MySQL checks every unique key for conflicts.
+func mysqlUpsertClause(target []string, update []string) string {
+	if len(update) == 0 {
+		// MySQL has no do nothing, setting a column to itself changes nothing.
+		column := mysqlQuoteIdentifier(target[0])
+		return fmt.Sprintf("ON DUPLICATE KEY UPDATE %s = %s", column, column)
+	}
+	var sets []string
+	for _, name := range update {
+		column := mysqlQuoteIdentifier(name)
+		sets = append(sets, fmt.Sprintf("%s = VALUES(%s)", column, column))
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+}
+
+func standardUpsertClause(target []string, update []string) string {
+	var columns []string
+	for _, name := range target {
+		columns = append(columns, standardQuoteIdentifier(name))
+	}
+	if len(update) == 0 {
+		return fmt.Sprintf("ON CONFLICT (%s) DO NOTHING", strings.Join(columns, ", "))
+	}
+	var sets []string
+	for _, name := range update {
+		column := standardQuoteIdentifier(name)
+		sets = append(sets, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+	}
+	return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(columns, ", "), strings.Join(sets, ", "))
+}
+
+var plainIdentifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+
+// quoteIdentifier quotes name using dialect if it's a plain identifier or a dotted name like users.id or users.*,
+// anything else like expressions or already quoted identifiers is returned as is.
+func quoteIdentifier(d *Dialect, name string) string {
+	if d == nil || d.QuoteIdentifier == nil {
+		return name
+	}
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if part == "*" && i > 0 && i == len(parts)-1 {
+			continue
+		}
+		if !plainIdentifierRegex.MatchString(part) {
+			return name
+		}
+	}
+	for i, part := range parts {
+		if part != "*" {
+			parts[i] = d.QuoteIdentifier(part)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// quoteIdentifiers is like quoteIdentifier but for a list of names.
+func quoteIdentifiers(d *Dialect, names []string) []string {
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		quoted = append(quoted, quoteIdentifier(d, name))
+	}
+	return quoted
+}
@@ -16,6 +16,9 @@ type field struct {
 	IsDeletedAt bool
 	Nullable    bool
 	Default     any
+	Index       string
+	UniqueIndex string
+	Generated   bool
 	Type        reflect.Type
 }
 
@@ -25,9 +28,12 @@ type fieldTag struct {
 	PK          bool
 	Nullable    bool
 	Default     string
+	Index       string
+	UniqueIndex string
 	IsCreatedAt bool
 	IsUpdatedAt bool
 	IsDeletedAt bool
+	Generated   bool
 }
 
 func fieldMetadataFromTag(t string) fieldTag {
@@ -54,6 +60,12 @@ func fieldMetadataFromTag(t string) fieldTag {
 			tag.Nullable = true
 		} else if key == "default" {
 			tag.Default = value
+		} else if key == "index" {
+			tag.Index = value
+		} else if key == "unique" {
+			tag.UniqueIndex = value
+		} else if key == "generated" {
+			tag.Generated = true
 		}
 		if tag.Name == "_" {
 			tag.Virtual = true
@@ -99,6 +111,17 @@ func fieldMetadata(ft reflect.StructField, fieldConfigurators []*FieldConfigurat
 	if tagParsed.Virtual {
 		baseFm.Virtual = true
 	}
+	if tagParsed.Nullable || isNullableType(ft.Type) {
+		baseFm.Nullable = true
+	}
+	if tagParsed.Default != "" {
+		baseFm.Default = tagParsed.Default
+	}
+	baseFm.Index = tagParsed.Index
+	baseFm.UniqueIndex = tagParsed.UniqueIndex
+	if tagParsed.Generated || fc.isGenerated {
+		baseFm.Generated = true
+	}
 	if ft.Type.Kind() == reflect.Struct || ft.Type.Kind() == reflect.Ptr {
 		t := ft.Type
 		if ft.Type.Kind() == reflect.Ptr {
@@ -113,3 +136,12 @@ func fieldMetadata(ft reflect.StructField, fieldConfigurators []*FieldConfigurat
 	}
 	return fms
 }
+
+// isNullableType reports whether values of t can hold NULL, pointers and
+// sql.Null* kind of types can.
+func isNullableType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		return true
+	}
+	return t.Kind() == reflect.Struct && t.PkgPath() == "database/sql" && strings.HasPrefix(t.Name(), "Null")
+}
@@ -3,6 +3,7 @@ package orm
 import (
 	"fmt"
 	"reflect"
+	"strings"
 )
 
 func postgresPlaceholder(n int) []string {
@@ -22,6 +23,59 @@ func mySQLPlaceHolder(n int) []string {
 	return output
 }
 
+// sequentialPlaceholders wraps given generator so numbering continues between calls, so every
+// part of a query gets its own placeholders in dialects that include index in placeholders.
+func sequentialPlaceholders(generator func(n int) []string) func(n int) []string {
+	used := 0
+	return func(n int) []string {
+		phs := generator(used + n)[used:]
+		used += n
+		return phs
+	}
+}
+
+// rawWithPlaceholders replaces ? placeholders of a raw sql chunk with placeholders of the dialect, raw chunks are left
+// as they are on dialects that use ? themselves. ? inside quoted literals and identifiers is not a placeholder and ??
+// is a literal ?, for operators like ? and ?| of PostgreSQL jsonb.
+func rawWithPlaceholders(sql string, generator func(n int) []string, dialect *Dialect) string {
+	if generator == nil || (dialect != nil && dialect.PlaceholderChar == "?") || !strings.Contains(sql, "?") {
+		return sql
+	}
+	// parts are pieces of sql between placeholders.
+	var parts []string
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?' && i+1 < len(sql) && sql[i+1] == '?':
+			i++
+		case c == '?':
+			parts = append(parts, b.String())
+			b.Reset()
+			continue
+		}
+		b.WriteByte(c)
+	}
+	if len(parts) == 0 {
+		return b.String()
+	}
+	phs := generator(len(parts))
+	var output strings.Builder
+	for i, part := range parts {
+		output.WriteString(part)
+		output.WriteString(phs[i])
+	}
+	output.WriteString(b.String())
+	return output.String()
+}
+
 func entitiesAsList(entities []Entity) []string {
 	var output []string
 
@@ -7,9 +7,20 @@ import (
 
 type insertStmt struct {
 	PlaceHolderGenerator func(n int) []string
+	Dialect              *Dialect
 	Table                string
 	Columns              []string
 	Values               [][]interface{}
+	// Select is a query whose result is inserted instead of Values when it's set.
+	Select *raw
+	// Upsert is rendered after values when it's set.
+	Upsert *upsertClause
+}
+
+// upsertClause makes an insert update conflicting rows, see Dialect.UpsertClause.
+type upsertClause struct {
+	Target []string
+	Update []string
 }
 
 func (i insertStmt) flatValues() []interface{} {
@@ -32,10 +43,20 @@ func (i insertStmt) getValuesStr() string {
 }
 
 func (i insertStmt) ToSql() (string, []interface{}) {
-	base := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s",
-		i.Table,
-		strings.Join(i.Columns, ","),
-		i.getValuesStr(),
+	var rows string
+	var args []interface{}
+	if i.Select != nil {
+		rows, args = i.Select.sql, i.Select.args
+	} else {
+		rows, args = "VALUES "+i.getValuesStr(), i.flatValues()
+	}
+	base := fmt.Sprintf("INSERT INTO %s (%s) %s",
+		quoteIdentifier(i.Dialect, i.Table),
+		strings.Join(quoteIdentifiers(i.Dialect, i.Columns), ","),
+		rows,
 	)
-	return base, i.flatValues()
+	if i.Upsert != nil {
+		base += " " + i.Dialect.UpsertClause(i.Upsert.Target, i.Upsert.Update)
+	}
+	return base, args
 }
@@ -1,6 +1,8 @@
 package orm
 
 import (
+	"context"
+	"database/sql"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -28,4 +30,77 @@ func TestInsert(t *testing.T) {
 		assert.Equal(t, `INSERT INTO users (name,age) VALUES (?,?)`, s)
 		assert.Equal(t, []interface{}{"amirreza", 11}, args)
 	})
+	t.Run("upsert", func(t *testing.T) {
+		i := insertStmt{Table: "users", Columns: []string{"id", "email", "name"}, Values: [][]interface{}{{1, "a@b.c", "amirreza"}}}
+		i.Upsert = &upsertClause{Target: []string{"email"}, Update: []string{"name"}}
+		for _, c := range []struct {
+			dialect  *Dialect
+			expected string
+		}{
+			{Dialects.MySQL, "INSERT INTO `users` (`id`,`email`,`name`) VALUES (?,?,?) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`)"},
+			{Dialects.PostgreSQL, `INSERT INTO "users" ("id","email","name") VALUES ($1,$2,$3) ON CONFLICT ("email") DO UPDATE SET "name" = EXCLUDED."name"`},
+			{Dialects.SQLite3, `INSERT INTO "users" ("id","email","name") VALUES (?,?,?) ON CONFLICT ("email") DO UPDATE SET "name" = EXCLUDED."name"`},
+		} {
+			i.Dialect = c.dialect
+			i.PlaceHolderGenerator = c.dialect.PlaceHolderGenerator
+			s, _ := i.ToSql()
+			assert.Equal(t, c.expected, s)
+		}
+	})
+
+	t.Run("upsert do nothing", func(t *testing.T) {
+		i := insertStmt{Table: "users", Columns: []string{"email"}, Values: [][]interface{}{{"a@b.c"}}}
+		i.Upsert = &upsertClause{Target: []string{"email"}}
+		i.Dialect, i.PlaceHolderGenerator = Dialects.MySQL, Dialects.MySQL.PlaceHolderGenerator
+		s, _ := i.ToSql()
+		assert.Equal(t, "INSERT INTO `users` (`email`) VALUES (?) ON DUPLICATE KEY UPDATE `email` = `email`", s)
+		i.Dialect, i.PlaceHolderGenerator = Dialects.PostgreSQL, Dialects.PostgreSQL.PlaceHolderGenerator
+		s, _ = i.ToSql()
+		assert.Equal(t, `INSERT INTO "users" ("email") VALUES ($1) ON CONFLICT ("email") DO NOTHING`, s)
+	})
+
+	t.Run("insert from query", func(t *testing.T) {
+		generator := sequentialPlaceholders(Dialects.PostgreSQL.PlaceHolderGenerator)
+		sub, args, err := NewQueryBuilder[Dummy]().Table("posts").Select("id", "body").Where("id", ">", 10).AndWhere("body", "<>", "").
+			subquery(generator, Dialects.PostgreSQL)
+		assert.NoError(t, err)
+		i := insertStmt{Table: "archived_posts", Columns: []string{"id", "body"}, Select: &raw{sql: sub, args: args}}
+		i.Dialect, i.PlaceHolderGenerator = Dialects.PostgreSQL, generator
+		s, args := i.ToSql()
+		assert.Equal(t, `INSERT INTO "archived_posts" ("id","body") SELECT "id","body" FROM "posts" WHERE "id" > $1 AND "body" <> $2`, s)
+		assert.Equal(t, []interface{}{10, ""}, args)
+	})
+}
+
+func TestInsertReturning(t *testing.T) {
+	setup(t)
+	s := getSchemaFor(&Object{})
+	fields := s.fields[:2]
+	// rows of RETURNING can come in any order on SQLite.
+	q := `SELECT 2, 'second' UNION ALL SELECT 1, 'first'`
+	t.Run("rows are matched to entities by primary key", func(t *testing.T) {
+		first, second := &Object{}, &Object{}
+		assert.NoError(t, insertReturning(context.Background(), GetConnection("default"), fields, q, nil, []Entity{first, second}, true))
+		assert.Equal(t, int64(1), first.ID)
+		assert.Equal(t, "first", first.Name)
+		assert.Equal(t, int64(2), second.ID)
+		assert.Equal(t, "second", second.Name)
+	})
+	t.Run("missing rows are reported", func(t *testing.T) {
+		err := insertReturning(context.Background(), GetConnection("default"), fields, q, nil, []Entity{&Object{}, &Object{}, &Object{}}, true)
+		assert.EqualError(t, err, "insert returned 2 rows but 3 rows are inserted")
+	})
+}
+
+func TestMySQLDetectFallsBackToRowByRow(t *testing.T) {
+	// sqlite has no mysql server variables so reading them fails.
+	db, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	defer db.Close()
+	d := *Dialects.MySQL
+	d.ConsecutiveInsertIDs = true
+	detected, err := mysqlDetect(context.Background(), db, &d)
+	assert.NoError(t, err)
+	assert.False(t, detected.ConsecutiveInsertIDs)
+	assert.True(t, detected.SupportsLastInsertID)
 }
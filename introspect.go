@@ -0,0 +1,208 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Column is a column of a table as it's reported by the database itself.
+type Column struct {
+	// Name of the column.
+	Name string
+	// Type is the SQL type of the column as database reports it, for example bigint or varchar(255).
+	Type string
+	// Nullable is true when column accepts NULL values.
+	Nullable bool
+	// Default is the default value expression of the column if it has any.
+	Default sql.NullString
+	// IsPK is true when column is part of the primary key.
+	IsPK bool
+}
+
+// Index is an index of a table as it's reported by the database itself,
+// primary keys are not reported as indexes.
+type Index struct {
+	// Name of the index.
+	Name string
+	// Columns of the index in order.
+	Columns []string
+	// Unique is true when index is a unique index.
+	Unique bool
+}
+
+// Tables lists tables that exist in the database of the connection.
+func (c *connection) Tables() ([]string, error) {
+	return c.TablesContext(context.Background())
+}
+
+// TablesContext is like Tables but uses given context for executing queries.
+func (c *connection) TablesContext(ctx context.Context) ([]string, error) {
+	if c.Dialect.ListTables == nil {
+		return nil, fmt.Errorf("dialect %s does not support listing tables", c.Dialect.DriverName)
+	}
+	return c.Dialect.ListTables(ctx, c.Connection)
+}
+
+// Columns lists columns of given table as they exist in the database of the connection.
+func (c *connection) Columns(table string) ([]*Column, error) {
+	return c.ColumnsContext(context.Background(), table)
+}
+
+// ColumnsContext is like Columns but uses given context for executing queries.
+func (c *connection) ColumnsContext(ctx context.Context, table string) ([]*Column, error) {
+	if c.Dialect.ListColumns == nil {
+		return nil, fmt.Errorf("dialect %s does not support listing columns", c.Dialect.DriverName)
+	}
+	return c.Dialect.ListColumns(ctx, c.Connection, table)
+}
+
+// Indexes lists indexes of given table as they exist in the database of the connection.
+func (c *connection) Indexes(table string) ([]*Index, error) {
+	return c.IndexesContext(context.Background(), table)
+}
+
+// IndexesContext is like Indexes but uses given context for executing queries.
+func (c *connection) IndexesContext(ctx context.Context, table string) ([]*Index, error) {
+	if c.Dialect.ListIndexes == nil {
+		return nil, fmt.Errorf("dialect %s does not support listing indexes", c.Dialect.DriverName)
+	}
+	return c.Dialect.ListIndexes(ctx, c.Connection, table)
+}
+
+func scanTables(rows *sql.Rows, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+// scanIndexes reads rows of (index name, column name, is unique) ordered by index name
+// and position of column in the index.
+func scanIndexes(rows *sql.Rows, err error) ([]*Index, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var indexes []*Index
+	for rows.Next() {
+		var name, column string
+		var unique bool
+		if err := rows.Scan(&name, &column, &unique); err != nil {
+			return nil, err
+		}
+		if len(indexes) == 0 || indexes[len(indexes)-1].Name != name {
+			indexes = append(indexes, &Index{Name: name, Unique: unique})
+		}
+		idx := indexes[len(indexes)-1]
+		idx.Columns = append(idx.Columns, column)
+	}
+	return indexes, rows.Err()
+}
+
+func mysqlListTables(ctx context.Context, db *sql.DB) ([]string, error) {
+	return scanTables(db.QueryContext(ctx, `SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE' ORDER BY table_name`))
+}
+
+func mysqlListColumns(ctx context.Context, db *sql.DB, table string) ([]*Column, error) {
+	rows, err := db.QueryContext(ctx, `SELECT column_name, column_type, is_nullable, column_default, column_key FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? ORDER BY ordinal_position`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []*Column
+	for rows.Next() {
+		var c Column
+		var nullable, key string
+		if err := rows.Scan(&c.Name, &c.Type, &nullable, &c.Default, &key); err != nil {
+			return nil, err
+		}
+		c.Nullable = nullable == "YES"
+		c.IsPK = key == "PRI"
+		columns = append(columns, &c)
+	}
+	return columns, rows.Err()
+}
+
+func mysqlListIndexes(ctx context.Context, db *sql.DB, table string) ([]*Index, error) {
+	return scanIndexes(db.QueryContext(ctx, `SELECT index_name, column_name, non_unique = 0 FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name <> 'PRIMARY' ORDER BY index_name, seq_in_index`, table))
+}
+
+func postgresListTables(ctx context.Context, db *sql.DB) ([]string, error) {
+	return scanTables(db.QueryContext(ctx, `SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() AND table_type = 'BASE TABLE' ORDER BY table_name`))
+}
+
+func postgresListColumns(ctx context.Context, db *sql.DB, table string) ([]*Column, error) {
+	rows, err := db.QueryContext(ctx, `SELECT c.column_name, c.data_type, c.is_nullable, c.column_default,
+	EXISTS (
+		SELECT 1 FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage kcu
+			ON tc.constraint_name = kcu.constraint_name AND tc.table_schema = kcu.table_schema AND tc.table_name = kcu.table_name
+		WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_schema = c.table_schema AND tc.table_name = c.table_name AND kcu.column_name = c.column_name
+	)
+FROM information_schema.columns c WHERE c.table_schema = current_schema() AND c.table_name = $1 ORDER BY c.ordinal_position`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []*Column
+	for rows.Next() {
+		var c Column
+		var nullable string
+		if err := rows.Scan(&c.Name, &c.Type, &nullable, &c.Default, &c.IsPK); err != nil {
+			return nil, err
+		}
+		c.Nullable = nullable == "YES"
+		columns = append(columns, &c)
+	}
+	return columns, rows.Err()
+}
+
+func postgresListIndexes(ctx context.Context, db *sql.DB, table string) ([]*Index, error) {
+	return scanIndexes(db.QueryContext(ctx, `SELECT i.relname, a.attname, ix.indisunique
+FROM pg_class t
+JOIN pg_namespace n ON n.oid = t.relnamespace
+JOIN pg_index ix ON ix.indrelid = t.oid
+JOIN pg_class i ON i.oid = ix.indexrelid
+JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = ANY(ix.indkey)
+WHERE n.nspname = current_schema() AND t.relname = $1 AND NOT ix.indisprimary
+ORDER BY i.relname, array_position(ix.indkey::int2[], a.attnum)`, table))
+}
+
+func sqliteListTables(ctx context.Context, db *sql.DB) ([]string, error) {
+	return scanTables(db.QueryContext(ctx, `SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`))
+}
+
+func sqliteListColumns(ctx context.Context, db *sql.DB, table string) ([]*Column, error) {
+	rows, err := db.QueryContext(ctx, `SELECT name, type, "notnull", dflt_value, pk FROM pragma_table_info(?) ORDER BY cid`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []*Column
+	for rows.Next() {
+		var c Column
+		var notNull bool
+		var pk int
+		if err := rows.Scan(&c.Name, &c.Type, &notNull, &c.Default, &pk); err != nil {
+			return nil, err
+		}
+		c.IsPK = pk > 0
+		c.Nullable = !notNull && !c.IsPK
+		columns = append(columns, &c)
+	}
+	return columns, rows.Err()
+}
+
+func sqliteListIndexes(ctx context.Context, db *sql.DB, table string) ([]*Index, error) {
+	return scanIndexes(db.QueryContext(ctx, `SELECT il.name, ii.name, il."unique" FROM pragma_index_list(?) il JOIN pragma_index_info(il.name) ii WHERE il.origin = 'c' ORDER BY il.name, ii.seqno`, table))
+}
@@ -0,0 +1,176 @@
+package orm
+
+import (
+	"context"
+	"fmt"
+)
+
+// MigrateConfig configures how AutoMigrate applies changes.
+type MigrateConfig struct {
+	// DryRun only plans changes and returns them without executing anything,
+	// useful for reviewing what AutoMigrate is going to do in CI.
+	DryRun bool
+	// AllowDestructive executes destructive changes too, by default dropping
+	// or changing type of columns is only reported in MigrationPlan.Destructive.
+	AllowDestructive bool
+}
+
+// MigrationPlan contains changes needed to make database of a connection match its entities.
+type MigrationPlan struct {
+	// Connection is name of the connection that plan belongs to.
+	Connection string
+	// Dialect is driver name of the connection dialect that statements are generated for.
+	Dialect string
+	// Statements are safe changes, creating tables and adding columns and indexes.
+	Statements []string
+	// Destructive are statements that drop columns or change their type, they are only
+	// executed if MigrateConfig.AllowDestructive is set.
+	Destructive []string
+	// Warnings are changes that are needed but cannot be done automatically.
+	Warnings []string
+}
+
+// AutoMigrate compares given entities with tables in their database and creates missing tables, columns and indexes,
+// destructive changes are not executed and only reported in returned plans, one plan for each connection.
+func AutoMigrate(entities ...Entity) ([]*MigrationPlan, error) {
+	return AutoMigrateContext(context.Background(), MigrateConfig{}, entities...)
+}
+
+// AutoMigrateWithConfig is like AutoMigrate but applies changes based on given config.
+func AutoMigrateWithConfig(config MigrateConfig, entities ...Entity) ([]*MigrationPlan, error) {
+	return AutoMigrateContext(context.Background(), config, entities...)
+}
+
+// AutoMigrateContext is like AutoMigrateWithConfig but uses given context for executing queries.
+func AutoMigrateContext(ctx context.Context, config MigrateConfig, entities ...Entity) ([]*MigrationPlan, error) {
+	var plans []*MigrationPlan
+	byConnection := map[*connection]*MigrationPlan{}
+	var connections []*connection
+	for _, s := range sortByDependency(entities) {
+		c := s.getConnection()
+		plan, exists := byConnection[c]
+		if !exists {
+			plan = &MigrationPlan{Connection: c.Name, Dialect: c.Dialect.DriverName}
+			byConnection[c] = plan
+			plans = append(plans, plan)
+			connections = append(connections, c)
+		}
+		if err := planSchema(ctx, c, s, plan); err != nil {
+			return nil, err
+		}
+	}
+	if config.DryRun {
+		return plans, nil
+	}
+	// every plan is checked before anything is executed so a plan that cannot be applied leaves all databases untouched.
+	if config.AllowDestructive {
+		for _, plan := range plans {
+			if len(plan.Warnings) > 0 {
+				return plans, fmt.Errorf("cannot apply destructive changes on connection %s: %s", plan.Connection, plan.Warnings[0])
+			}
+		}
+	}
+	for i, plan := range plans {
+		statements := plan.Statements
+		if config.AllowDestructive {
+			statements = append(statements, plan.Destructive...)
+		}
+		if err := applyStatements(ctx, connections[i], statements); err != nil {
+			return plans, err
+		}
+	}
+	return plans, nil
+}
+
+// applyStatements executes statements of a plan, inside a transaction if dialect supports transactional DDL
+// so a failing statement leaves no change behind.
+func applyStatements(ctx context.Context, c *connection, statements []string) error {
+	run := func(exec executor) error {
+		for _, stmt := range statements {
+			if _, err := exec.exec(ctx, stmt); err != nil {
+				return fmt.Errorf("executing %s: %w", stmt, err)
+			}
+		}
+		return nil
+	}
+	if len(statements) == 0 || !c.Dialect.TransactionalDDL {
+		return run(c)
+	}
+	return c.TransactionContext(ctx, func(tx *Tx) error {
+		return run(tx)
+	})
+}
+
+// planSchema adds changes needed for table of given schema to the plan.
+func planSchema(ctx context.Context, c *connection, s *schema, plan *MigrationPlan) error {
+	dialect := c.Dialect
+	if dialect.ColumnType == nil || dialect.AutoIncrementColumn == nil || dialect.ListColumns == nil || dialect.ListIndexes == nil {
+		return fmt.Errorf("dialect %s does not support migrations", dialect.DriverName)
+	}
+	columns, err := dialect.ListColumns(ctx, c.Connection, s.Table)
+	if err != nil {
+		return fmt.Errorf("listing columns of %s: %w", s.Table, err)
+	}
+	if len(columns) == 0 {
+		plan.Statements = append(plan.Statements, createTableSQL(dialect, s))
+		for _, idx := range s.indexes() {
+			plan.Statements = append(plan.Statements, createIndexSQL(dialect, s.Table, idx))
+		}
+		return nil
+	}
+
+	existing := map[string]*Column{}
+	for _, column := range columns {
+		existing[column.Name] = column
+	}
+	fields := map[string]bool{}
+	for _, f := range s.fields {
+		if f.Virtual {
+			continue
+		}
+		fields[f.Name] = true
+		column, exists := existing[f.Name]
+		if !exists {
+			plan.Statements = append(plan.Statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", quoteIdentifier(dialect, s.Table), addedColumnDefinition(dialect, f)))
+			continue
+		}
+		if isCompatibleKind(kindOf(f.Type), kindOfSQLType(column.Type)) {
+			continue
+		}
+		if dialect.ModifyColumn == nil {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("%s does not support changing type of column %s.%s from %s to %s", dialect.DriverName, s.Table, f.Name, column.Type, dialect.ColumnType(f.Type)))
+			continue
+		}
+		plan.Destructive = append(plan.Destructive, dialect.ModifyColumn(s.Table, f))
+	}
+	for _, column := range columns {
+		if !fields[column.Name] {
+			plan.Destructive = append(plan.Destructive, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", quoteIdentifier(dialect, s.Table), quoteIdentifier(dialect, column.Name)))
+		}
+	}
+
+	indexes, err := dialect.ListIndexes(ctx, c.Connection, s.Table)
+	if err != nil {
+		return fmt.Errorf("listing indexes of %s: %w", s.Table, err)
+	}
+	existingIndexes := map[string]bool{}
+	for _, idx := range indexes {
+		existingIndexes[idx.Name] = true
+	}
+	for _, idx := range s.indexes() {
+		if !existingIndexes[idx.Name] {
+			plan.Statements = append(plan.Statements, createIndexSQL(dialect, s.Table, idx))
+		}
+	}
+	return nil
+}
+
+// addedColumnDefinition is like columnDefinition but for columns added to an existing table,
+// since existing rows have no value for them they are nullable unless they have a default.
+func addedColumnDefinition(dialect *Dialect, f *field) string {
+	added := *f
+	if added.Default == nil {
+		added.Nullable = true
+	}
+	return columnDefinition(dialect, &added)
+}
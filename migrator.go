@@ -0,0 +1,347 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const migrationsTable = "schema_migrations"
+
+// SQLExecutor is what migrations use to run their queries, it's either a *sql.Tx when
+// dialect supports transactional DDL or the *sql.DB of the connection.
+type SQLExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// MigrationFunc applies or reverts a migration.
+type MigrationFunc func(ctx context.Context, db SQLExecutor) error
+
+// Migration is a versioned change to the database schema.
+type Migration struct {
+	// Version of the migration, migrations are applied in lexical order of their versions
+	// so use fixed width versions like timestamps, for example 20220301120000.
+	Version string
+	// Name describes what migration does.
+	Name string
+	// Up applies the migration.
+	Up MigrationFunc
+	// Down reverts the migration, migrations without Down cannot be reverted.
+	Down MigrationFunc
+}
+
+// MigrationStatus is state of a migration in a database.
+type MigrationStatus struct {
+	Version string
+	// Name is empty for migrations that are applied but are not registered in the migrator anymore.
+	Name    string
+	Applied bool
+}
+
+// Migrator runs versioned migrations on a connection and keeps track of applied
+// ones in schema_migrations table of the connection database.
+type Migrator struct {
+	conn       *connection
+	migrations map[string]*Migration
+	err        error
+}
+
+// NewMigrator creates a Migrator for given connection, empty name means the default connection.
+func NewMigrator(name string) (*Migrator, error) {
+	var c *connection
+	if name == "" {
+		var err error
+		if c, err = defaultConnection(); err != nil {
+			return nil, err
+		}
+	} else if c = GetConnection(name); c == nil {
+		return nil, fmt.Errorf("no connection named %s", name)
+	}
+	return &Migrator{conn: c, migrations: map[string]*Migration{}}, nil
+}
+
+// Add registers a migration implemented by Go functions, down can be nil.
+func (m *Migrator) Add(version string, name string, up MigrationFunc, down MigrationFunc) *Migrator {
+	if up == nil {
+		m.err = fmt.Errorf("migration %s has no up", version)
+		return m
+	}
+	if _, exists := m.migrations[version]; exists {
+		m.err = fmt.Errorf("migration %s is registered more than once", version)
+		return m
+	}
+	m.migrations[version] = &Migration{Version: version, Name: name, Up: up, Down: down}
+	return m
+}
+
+// AddSQL registers a migration implemented by SQL queries, down can be empty.
+// Each query can contain multiple statements if database driver supports it,
+// for MySQL it needs multiStatements=true in DSN.
+func (m *Migrator) AddSQL(version string, name string, up string, down string) *Migrator {
+	var downFunc MigrationFunc
+	if down != "" {
+		downFunc = execSQL(down)
+	}
+	return m.Add(version, name, execSQL(up), downFunc)
+}
+
+var migrationFileRegex = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// AddFS registers SQL migrations in dir of given file system, for example an embed.FS.
+// Files should be named like <version>_<name>.up.sql and <version>_<name>.down.sql.
+func (m *Migrator) AddFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	type files struct{ name, up, down string }
+	byVersion := map[string]*files{}
+	var versions []string
+	for _, entry := range entries {
+		match := migrationFileRegex.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		version := match[1]
+		f, exists := byVersion[version]
+		if !exists {
+			f = &files{name: match[2]}
+			byVersion[version] = f
+			versions = append(versions, version)
+		}
+		if match[3] == "up" {
+			f.up = string(content)
+		} else {
+			f.down = string(content)
+		}
+	}
+	for _, version := range versions {
+		f := byVersion[version]
+		if f.up == "" {
+			return fmt.Errorf("migration %s_%s has no up file", version, f.name)
+		}
+		m.AddSQL(version, f.name, f.up, f.down)
+	}
+	return m.err
+}
+
+func execSQL(q string) MigrationFunc {
+	return func(ctx context.Context, db SQLExecutor) error {
+		_, err := db.ExecContext(ctx, q)
+		return err
+	}
+}
+
+// Up applies all pending migrations in order of their versions.
+func (m *Migrator) Up() error {
+	return m.UpContext(context.Background())
+}
+
+// UpContext is like Up but uses given context for executing queries.
+func (m *Migrator) UpContext(ctx context.Context) error {
+	pending, err := m.pending(ctx)
+	if err != nil {
+		return err
+	}
+	for _, migration := range pending {
+		if err := m.run(ctx, migration, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Down reverts last n applied migrations.
+func (m *Migrator) Down(n int) error {
+	return m.DownContext(context.Background(), n)
+}
+
+// DownContext is like Down but uses given context for executing queries.
+func (m *Migrator) DownContext(ctx context.Context, n int) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	for i := len(applied) - 1; i >= 0 && i >= len(applied)-n; i-- {
+		migration, exists := m.migrations[applied[i]]
+		if !exists {
+			return fmt.Errorf("migration %s is applied but not registered", applied[i])
+		}
+		if err := m.run(ctx, migration, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Redo reverts the last applied migration and applies it again.
+func (m *Migrator) Redo() error {
+	return m.RedoContext(context.Background())
+}
+
+// RedoContext is like Redo but uses given context for executing queries.
+func (m *Migrator) RedoContext(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		return fmt.Errorf("there is no applied migration to redo")
+	}
+	migration, exists := m.migrations[applied[len(applied)-1]]
+	if !exists {
+		return fmt.Errorf("migration %s is applied but not registered", applied[len(applied)-1])
+	}
+	if err := m.run(ctx, migration, false); err != nil {
+		return err
+	}
+	return m.run(ctx, migration, true)
+}
+
+// Status returns state of all registered and applied migrations in order of their versions.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	return m.StatusContext(context.Background())
+}
+
+// StatusContext is like Status but uses given context for executing queries.
+func (m *Migrator) StatusContext(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	isApplied := map[string]bool{}
+	versions := m.versions()
+	for _, version := range applied {
+		isApplied[version] = true
+		if _, exists := m.migrations[version]; !exists {
+			versions = append(versions, version)
+		}
+	}
+	sort.Strings(versions)
+	var statuses []MigrationStatus
+	for _, version := range versions {
+		status := MigrationStatus{Version: version, Applied: isApplied[version]}
+		if migration, exists := m.migrations[version]; exists {
+			status.Name = migration.Name
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// CheckPending returns an error if there are migrations that are not applied yet,
+// you can use it to refuse starting your application with an outdated database.
+func (m *Migrator) CheckPending() error {
+	return m.CheckPendingContext(context.Background())
+}
+
+// CheckPendingContext is like CheckPending but uses given context for executing queries.
+func (m *Migrator) CheckPendingContext(ctx context.Context) error {
+	pending, err := m.pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	var names []string
+	for _, migration := range pending {
+		names = append(names, fmt.Sprintf("%s_%s", migration.Version, migration.Name))
+	}
+	return fmt.Errorf("there are %d pending migrations: %s", len(pending), strings.Join(names, ", "))
+}
+
+func (m *Migrator) versions() []string {
+	var versions []string
+	for version := range m.migrations {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+func (m *Migrator) pending(ctx context.Context) ([]*Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	isApplied := map[string]bool{}
+	for _, version := range applied {
+		isApplied[version] = true
+	}
+	var pending []*Migration
+	for _, version := range m.versions() {
+		if !isApplied[version] {
+			pending = append(pending, m.migrations[version])
+		}
+	}
+	return pending, nil
+}
+
+// applied returns versions of applied migrations in order, it creates the migrations table if it does not exist.
+func (m *Migrator) applied(ctx context.Context) ([]string, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if _, err := m.conn.exec(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version VARCHAR(255) NOT NULL PRIMARY KEY, applied_at TIMESTAMP NOT NULL)", migrationsTable)); err != nil {
+		return nil, err
+	}
+	rows, err := m.conn.query(UsePrimary(ctx), fmt.Sprintf("SELECT version FROM %s ORDER BY version", migrationsTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var versions []string
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+// run applies or reverts given migration and records it in migrations table, both happen in
+// a single transaction if dialect supports transactional DDL.
+func (m *Migrator) run(ctx context.Context, migration *Migration, up bool) error {
+	f := migration.Up
+	record := fmt.Sprintf("INSERT INTO %s (version, applied_at) VALUES (%s)", migrationsTable, strings.Join(m.conn.Dialect.PlaceHolderGenerator(2), ", "))
+	args := []any{migration.Version, time.Now().UTC()}
+	if !up {
+		if migration.Down == nil {
+			return fmt.Errorf("migration %s_%s cannot be reverted, it has no down", migration.Version, migration.Name)
+		}
+		f = migration.Down
+		record = fmt.Sprintf("DELETE FROM %s WHERE version = %s", migrationsTable, m.conn.Dialect.PlaceHolderGenerator(1)[0])
+		args = args[:1]
+	}
+	var err error
+	if m.conn.Dialect.TransactionalDDL {
+		err = m.conn.TransactionContext(ctx, func(tx *Tx) error {
+			if err := f(ctx, tx.tx); err != nil {
+				return err
+			}
+			_, err := tx.exec(ctx, record, args...)
+			return err
+		})
+	} else if err = f(ctx, m.conn.Connection); err == nil {
+		_, err = m.conn.exec(ctx, record, args...)
+	}
+	if err != nil {
+		return fmt.Errorf("migration %s_%s: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
@@ -1,9 +1,13 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	// Drivers
@@ -44,6 +48,13 @@ type ConnectionConfig struct {
 	// If you already have an active database connection configured pass it in this value and
 	// do not pass Driver and DSN fields.
 	DB *sql.DB
+	// Connection strings of read replicas of your database, they will be opened using Driver.
+	// Read queries are load balanced between replicas and writes always go to the primary
+	// which is DSN or DB.
+	ReplicaDSNs []string
+	// If you already have active connections to your read replicas pass them in this value
+	// instead of ReplicaDSNs.
+	Replicas []*sql.DB
 	// Which dialect of sql to generate queries for, you don't need it most of the times when you are using
 	// traditional databases such as mysql, sqlite3, postgres.
 	Dialect *Dialect
@@ -52,6 +63,10 @@ type ConnectionConfig struct {
 	// information that we can provide you and also potentialy validations that we
 	// can do with the database
 	Entities []Entity
+	// Validate checks Entities against the database when connection is set up, every table,
+	// column and foreign key column of relations should exist and columns types should be
+	// compatible with fields, otherwise SetupConnection returns a *ValidationError listing all problems.
+	Validate bool
 }
 
 // SetupConnection declares a new connection for ORM.
@@ -66,6 +81,8 @@ func SetupConnection(conf ConnectionConfig) error {
 
 	var dialect *Dialect
 	var db *sql.DB
+	// opened are connections opened here, they are closed if setup fails, connections passed in config are left open.
+	var opened []*sql.DB
 	if conf.DB != nil && conf.Dialect != nil {
 		globalLogger.Infof("Configuring an open connection")
 		dialect = conf.Dialect
@@ -80,18 +97,56 @@ func SetupConnection(conf ConnectionConfig) error {
 		if err != nil {
 			return err
 		}
+		opened = append(opened, db)
+	}
+	driver := conf.Driver
+	if driver == "" {
+		driver = dialect.DriverName
+	}
+	for _, dsn := range conf.ReplicaDSNs {
+		replica, err := sql.Open(driver, dsn)
+		if err != nil {
+			closeAll(opened)
+			return err
+		}
+		opened = append(opened, replica)
+		conf.Replicas = append(conf.Replicas, replica)
+	}
+	if dialect.Detect != nil {
+		if dialect, err = dialect.Detect(context.Background(), db, dialect); err != nil {
+			closeAll(opened)
+			return err
+		}
 	}
 	conf.DB = db
 	conf.Dialect = dialect
 
-	_, err = initialize(conf)
+	c, err := initialize(conf)
 	if err != nil {
+		closeAll(opened)
 		return err
 	}
 
+	// connection is registered only after it's validated so a failed setup leaves no connection behind.
+	if conf.Validate {
+		if err := c.validate(context.Background()); err != nil {
+			closeAll(opened)
+			return err
+		}
+	}
+	globalConnections[c.Name] = c
+	globalLogger.Infof("%s registered successfully.", c.Name)
+
 	return nil
 }
 
+// closeAll closes given connections, errors are ignored since it's only used when setup has already failed.
+func closeAll(dbs []*sql.DB) {
+	for _, db := range dbs {
+		_ = db.Close()
+	}
+}
+
 func initialize(config ConnectionConfig) (*connection, error) {
 	schemas := map[string]*schema{}
 	if config.Name == "" {
@@ -108,11 +163,10 @@ func initialize(config ConnectionConfig) (*connection, error) {
 	s := &connection{
 		Name:       config.Name,
 		Connection: config.DB,
+		Replicas:   config.Replicas,
 		Schemas:    schemas,
 		Dialect:    config.Dialect,
 	}
-	globalConnections[fmt.Sprintf("%s", config.Name)] = s
-	globalLogger.Infof("%s registered successfully.", config.Name)
 	return s, nil
 }
 
@@ -141,45 +195,218 @@ func getDialect(driver string) (*Dialect, error) {
 // Insert given entities into database based on their ConfigureEntity
 // we can find table and also Connection name.
 func Insert(objs ...Entity) error {
+	return insert(context.Background(), nil, objs...)
+}
+
+// InsertContext is like Insert but uses given context for executing query.
+func InsertContext(ctx context.Context, objs ...Entity) error {
+	return insert(ctx, nil, objs...)
+}
+
+func insert(ctx context.Context, tx *Tx, objs ...Entity) error {
 	if len(objs) == 0 {
 		return nil
 	}
 	globalLogger.Debugf("Going to insert %d objects", len(objs))
 	s := getSchemaFor(objs[0])
-	cols := s.Columns(false)
-	var values [][]interface{}
+	cols := fieldNames(s.writableFields(false))
 	for _, obj := range objs {
-		createdAtF := s.createdAt()
-		if createdAtF != nil {
-			genericSet(obj, createdAtF.Name, sql.NullTime{Time: time.Now(), Valid: true})
+		if !shouldSkipTimestamps(ctx) {
+			createdAtF := s.createdAt()
+			if createdAtF != nil {
+				genericSet(obj, createdAtF.Name, sql.NullTime{Time: time.Now(), Valid: true})
+			}
+			updatedAtF := s.updatedAt()
+			if updatedAtF != nil {
+				genericSet(obj, updatedAtF.Name, sql.NullTime{Time: time.Now(), Valid: true})
+			}
 		}
-		updatedAtF := s.updatedAt()
-		if updatedAtF != nil {
-			genericSet(obj, updatedAtF.Name, sql.NullTime{Time: time.Now(), Valid: true})
+	}
+
+	maxPlaceholders := s.getDialect().MaxPlaceholders
+	if insertsRowByRow(s) {
+		maxPlaceholders = len(cols)
+	}
+	chunks := chunkEntities(objs, len(cols), maxPlaceholders)
+	if len(chunks) > 1 && tx == nil {
+		// a single insert is atomic so chunks of it should be too.
+		return s.getConnection().TransactionContext(ctx, func(tx *Tx) error {
+			return insertChunks(ctx, tx, s, cols, chunks)
+		})
+	}
+	return insertChunks(ctx, tx, s, cols, chunks)
+}
+
+// insertsRowByRow reports whether entities of s should be inserted one row per query to get their primary keys,
+// that's when database only reports ID of one row of a multi-row insert and IDs of rows may not be consecutive,
+// or when it returns rows in arbitrary order and there is no integer primary key to match them to entities.
+func insertsRowByRow(s *schema) bool {
+	pk := s.pkField()
+	integerPK := pk != nil && isIntegerKind(kindOf(pk.Type))
+	dialect := s.getDialect()
+	if dialect.SupportsReturning {
+		return !dialect.ReturningInOrder && !integerPK && (pk != nil || len(s.generatedFields()) > 0)
+	}
+	return integerPK && dialect.SupportsLastInsertID && !dialect.ConsecutiveInsertIDs
+}
+
+// chunkEntities splits objs into chunks that each can be inserted using at most maxPlaceholders placeholders.
+func chunkEntities(objs []Entity, columns int, maxPlaceholders int) [][]Entity {
+	size := len(objs)
+	if maxPlaceholders > 0 && columns > 0 {
+		size = maxPlaceholders / columns
+		if size == 0 {
+			size = 1
 		}
-		values = append(values, genericValuesOf(obj, false))
 	}
+	var chunks [][]Entity
+	for len(objs) > size {
+		chunks = append(chunks, objs[:size])
+		objs = objs[size:]
+	}
+	return append(chunks, objs)
+}
+
+func insertChunks(ctx context.Context, tx *Tx, s *schema, cols []string, chunks [][]Entity) error {
+	for _, chunk := range chunks {
+		if err := insertChunk(ctx, tx, s, cols, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+// insertChunk inserts objs in a single query and sets their generated primary keys.
+func insertChunk(ctx context.Context, tx *Tx, s *schema, cols []string, objs []Entity) error {
+	var values [][]interface{}
+	for _, obj := range objs {
+		values = append(values, s.writableValuesOf(obj, false))
+	}
+	dialect := s.getDialect()
 	q, args := insertStmt{
-		PlaceHolderGenerator: s.getDialect().PlaceHolderGenerator,
+		PlaceHolderGenerator: dialect.PlaceHolderGenerator,
+		Dialect:              dialect,
 		Table:                s.getTable(),
 		Columns:              cols,
 		Values:               values,
 	}.ToSql()
 
-	res, err := s.getConnection().exec(q, args...)
+	exec, err := s.getExecutor(tx)
 	if err != nil {
 		return err
 	}
+	pk := s.pkField()
+	returning := s.generatedFields()
+	if pk != nil {
+		returning = append([]*field{pk}, returning...)
+	}
+	if dialect.SupportsReturning && len(returning) > 0 {
+		q += " RETURNING " + strings.Join(quoteIdentifiers(dialect, fieldNames(returning)), ",")
+		// rows returned in arbitrary order are sorted by primary key, insertsRowByRow makes sure it's an integer.
+		sortByPK := !dialect.ReturningInOrder && len(objs) > 1
+		return insertReturning(UsePrimary(ctx), exec, returning, q, args, objs, sortByPK)
+	}
+	res, err := exec.exec(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	if pk == nil || !isIntegerKind(kindOf(pk.Type)) || !dialect.SupportsLastInsertID {
+		return nil
+	}
 	id, err := res.LastInsertId()
 	if err != nil {
 		return err
 	}
+	// rows of a single insert get consecutive IDs, otherwise they are inserted one by one, see insertsRowByRow.
+	// LastInsertId is either ID of the first or the last one.
+	if !dialect.FirstInsertID {
+		id -= int64(len(objs) - 1)
+	}
+	for i, obj := range objs {
+		s.setPK(obj, reflect.ValueOf(id+int64(i)).Convert(pk.Type).Interface())
+	}
+	return nil
+}
 
-	getSchemaFor(objs[len(objs)-1]).setPK(objs[len(objs)-1], id)
+// insertReturning executes an insert that returns given fields of inserted rows and sets them on objs, rows are matched
+// to objs in order they are returned or in order of their primary key, which should be the first field, if sortByPK is set.
+func insertReturning(ctx context.Context, exec executor, fields []*field, q string, args []interface{}, objs []Entity, sortByPK bool) error {
+	rows, err := exec.query(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var returned [][]interface{}
+	for rows.Next() {
+		if len(returned) >= len(objs) {
+			return fmt.Errorf("insert returned more rows than inserted")
+		}
+		values := make([]interface{}, len(fields))
+		for j, f := range fields {
+			values[j] = reflect.New(f.Type).Interface()
+		}
+		if err := rows.Scan(values...); err != nil {
+			return err
+		}
+		returned = append(returned, values)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(returned) != len(objs) {
+		return fmt.Errorf("insert returned %d rows but %d rows are inserted", len(returned), len(objs))
+	}
+	if sortByPK {
+		sort.SliceStable(returned, func(i, j int) bool {
+			return integerValue(returned[i][0]) < integerValue(returned[j][0])
+		})
+	}
+	for i, values := range returned {
+		for j, f := range fields {
+			genericSet(objs[i], f.Name, reflect.ValueOf(values[j]).Elem().Interface())
+		}
+	}
 	return nil
 }
 
+// integerValue returns value of an integer scanned into v, v can be a pointer to an integer or to a nullable integer.
+func integerValue(v interface{}) int64 {
+	if valuer, ok := v.(driver.Valuer); ok {
+		v, _ = valuer.Value()
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return 0
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint())
+	}
+	return 0
+}
+
+// InsertFrom inserts rows selected by q into table of Target without reading them into Go, columns are columns of
+// Target that selected columns of q are inserted into in order. It runs inside transaction and context of q if it has them.
+//
+//	orm.InsertFrom[ArchivedPost]([]string{"id", "body"}, orm.Query[Post]().Select("id", "body").Where("created_at", "<", cutoff))
+func InsertFrom[Target Entity, Source Entity](columns []string, q *QueryBuilder[Source]) (sql.Result, error) {
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns given to insert into")
+	}
+	s := getSchemaFor(*new(Target))
+	if source := getSchemaFor(*new(Source)); source.getConnection() != s.getConnection() {
+		return nil, fmt.Errorf("cannot insert from %s into %s since they use different connections", source.Table, s.Table)
+	}
+	insert := Query[Target]().Columns(columns...).WithTx(q.tx).WithContext(q.getContext())
+	insert.insertFrom = q
+	return insert.Execute()
+}
+
 func isZero(val interface{}) bool {
 	switch val.(type) {
 	case int64:
@@ -196,17 +423,40 @@ func isZero(val interface{}) bool {
 // primary key is zero value we will
 // insert it.
 func Save(obj Entity) error {
+	return save(context.Background(), nil, obj)
+}
+
+// SaveContext is like Save but uses given context for executing query.
+func SaveContext(ctx context.Context, obj Entity) error {
+	return save(ctx, nil, obj)
+}
+
+func save(ctx context.Context, tx *Tx, obj Entity) error {
 	if isZero(getSchemaFor(obj).getPK(obj)) {
 		globalLogger.Debugf("Given object has no primary key set, going to insert it.")
-		return Insert(obj)
+		return insert(ctx, tx, obj)
 	} else {
 		globalLogger.Debugf("Given object has primary key set, going for update.")
-		return Update(obj)
+		return update(ctx, tx, obj)
 	}
 }
 
 // Find finds the Entity you want based on generic type and primary key you passed.
 func Find[T Entity](id interface{}) (T, error) {
+	return find[T](context.Background(), nil, id)
+}
+
+// FindContext is like Find but uses given context for executing query.
+func FindContext[T Entity](ctx context.Context, id interface{}) (T, error) {
+	return find[T](ctx, nil, id)
+}
+
+// FindTx is like Find but runs inside given transaction.
+func FindTx[T Entity](tx *Tx, id interface{}) (T, error) {
+	return find[T](tx.ctx, tx, id)
+}
+
+func find[T Entity](ctx context.Context, tx *Tx, id interface{}) (T, error) {
 	var q string
 	out := new(T)
 	md := getSchemaFor(*out)
@@ -215,11 +465,12 @@ func Find[T Entity](id interface{}) (T, error) {
 		Table(md.Table).
 		Select(md.Columns(true)...).
 		Where(md.pkName(), id).
+		setSchema(md).
 		ToSql()
 	if err != nil {
 		return *out, err
 	}
-	err = bind[T](out, q, args)
+	err = bind[T](ctx, tx, out, q, args)
 
 	if err != nil {
 		return *out, err
@@ -230,8 +481,8 @@ func Find[T Entity](id interface{}) (T, error) {
 
 func toTuples(obj Entity, withPK bool) [][2]interface{} {
 	var tuples [][2]interface{}
-	vs := genericValuesOf(obj, withPK)
-	cols := getSchemaFor(obj).Columns(withPK)
+	vs := getSchemaFor(obj).writableValuesOf(obj, withPK)
+	cols := fieldNames(getSchemaFor(obj).writableFields(withPK))
 	for i, col := range cols {
 		tuples = append(tuples, [2]interface{}{
 			col,
@@ -241,33 +492,131 @@ func toTuples(obj Entity, withPK bool) [][2]interface{} {
 	return tuples
 }
 
-// Update given Entity in database.
+// Update given Entity in database, if Entity has an updated at field it will be set to now.
 func Update(obj Entity) error {
+	return update(context.Background(), nil, obj)
+}
+
+// UpdateContext is like Update but uses given context for executing query.
+func UpdateContext(ctx context.Context, obj Entity) error {
+	return update(ctx, nil, obj)
+}
+
+func update(ctx context.Context, tx *Tx, obj Entity) error {
 	s := getSchemaFor(obj)
+	if updatedAtF := s.updatedAt(); updatedAtF != nil && !shouldSkipTimestamps(ctx) {
+		genericSet(obj, updatedAtF.Name, sql.NullTime{Time: time.Now(), Valid: true})
+	}
 	q, args, err := NewQueryBuilder[Entity]().SetDialect(s.getDialect()).Sets(toTuples(obj, false)...).Where(s.pkName(), genericGetPKValue(obj)).Table(s.Table).ToSql()
 
 	if err != nil {
 		return err
 	}
-	_, err = s.getConnection().exec(q, args...)
+	exec, err := s.getExecutor(tx)
+	if err != nil {
+		return err
+	}
+	_, err = exec.exec(ctx, q, args...)
 	return err
 }
 
-// Delete given Entity from database
+// Delete given Entity from database, if Entity has a deleted at field it will
+// be soft deleted by setting that field instead of removing the row.
 func Delete(obj Entity) error {
+	return del(context.Background(), nil, obj)
+}
+
+// DeleteContext is like Delete but uses given context for executing query.
+func DeleteContext(ctx context.Context, obj Entity) error {
+	return del(ctx, nil, obj)
+}
+
+func del(ctx context.Context, tx *Tx, obj Entity) error {
+	s := getSchemaFor(obj)
+	deletedAtF := s.deletedAt()
+	if deletedAtF == nil {
+		return forceDelete(ctx, tx, obj)
+	}
+	now := time.Now()
+	query, args, err := NewQueryBuilder[Entity]().SetDialect(s.getDialect()).Table(s.Table).Set(deletedAtF.Name, now).Where(s.pkName(), genericGetPKValue(obj)).ToSql()
+	if err != nil {
+		return err
+	}
+	exec, err := s.getExecutor(tx)
+	if err != nil {
+		return err
+	}
+	_, err = exec.exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	genericSet(obj, deletedAtF.Name, sql.NullTime{Time: now, Valid: true})
+	return nil
+}
+
+// ForceDelete removes given Entity from database even if it supports soft deletes.
+func ForceDelete(obj Entity) error {
+	return forceDelete(context.Background(), nil, obj)
+}
+
+// ForceDeleteContext is like ForceDelete but uses given context for executing query.
+func ForceDeleteContext(ctx context.Context, obj Entity) error {
+	return forceDelete(ctx, nil, obj)
+}
+
+func forceDelete(ctx context.Context, tx *Tx, obj Entity) error {
 	s := getSchemaFor(obj)
-	genericSet(obj, "deleted_at", sql.NullTime{Time: time.Now(), Valid: true})
 	query, args, err := NewQueryBuilder[Entity]().SetDialect(s.getDialect()).Table(s.Table).Where(s.pkName(), genericGetPKValue(obj)).SetDelete().ToSql()
 	if err != nil {
 		return err
 	}
-	_, err = s.getConnection().exec(query, args...)
+	exec, err := s.getExecutor(tx)
+	if err != nil {
+		return err
+	}
+	_, err = exec.exec(ctx, query, args...)
 	return err
 }
 
-func bind[T Entity](output interface{}, q string, args []interface{}) error {
+// Restore restores given soft deleted Entity by setting its deleted at field to NULL.
+func Restore(obj Entity) error {
+	return restore(context.Background(), nil, obj)
+}
+
+// RestoreContext is like Restore but uses given context for executing query.
+func RestoreContext(ctx context.Context, obj Entity) error {
+	return restore(ctx, nil, obj)
+}
+
+func restore(ctx context.Context, tx *Tx, obj Entity) error {
+	s := getSchemaFor(obj)
+	deletedAtF := s.deletedAt()
+	if deletedAtF == nil {
+		return fmt.Errorf("%s does not support soft deletes", s.Table)
+	}
+	query, args, err := NewQueryBuilder[Entity]().SetDialect(s.getDialect()).Table(s.Table).Set(deletedAtF.Name, nil).Where(s.pkName(), genericGetPKValue(obj)).ToSql()
+	if err != nil {
+		return err
+	}
+	exec, err := s.getExecutor(tx)
+	if err != nil {
+		return err
+	}
+	_, err = exec.exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	genericSet(obj, deletedAtF.Name, sql.NullTime{})
+	return nil
+}
+
+func bind[T Entity](ctx context.Context, tx *Tx, output interface{}, q string, args []interface{}) error {
 	outputMD := getSchemaFor(*new(T))
-	rows, err := outputMD.getConnection().query(q, args...)
+	exec, err := outputMD.getExecutor(tx)
+	if err != nil {
+		return err
+	}
+	rows, err := exec.query(ctx, q, args...)
 	if err != nil {
 		return err
 	}
@@ -308,7 +657,8 @@ func HasMany[PROPERTY Entity](owner Entity) *QueryBuilder[PROPERTY] {
 		SetDialect(s.getDialect()).
 		Table(c.PropertyTable).
 		Select(outSchema.Columns(true)...).
-		Where(c.PropertyForeignKey, genericGetPKValue(owner))
+		Where(c.PropertyForeignKey, genericGetPKValue(owner)).
+		setSchema(outSchema)
 }
 
 // HasOneConfig contains all information we need for a HasOne relationship,
@@ -343,7 +693,8 @@ func HasOne[PROPERTY Entity](owner Entity) *QueryBuilder[PROPERTY] {
 		SetDialect(property.getDialect()).
 		Table(c.PropertyTable).
 		Select(property.Columns(true)...).
-		Where(c.PropertyForeignKey, genericGetPKValue(owner))
+		Where(c.PropertyForeignKey, genericGetPKValue(owner)).
+		setSchema(property)
 }
 
 // BelongsToConfig contains all information we need for a BelongsTo relationship
@@ -388,7 +739,8 @@ func BelongsTo[OWNER Entity](property Entity) *QueryBuilder[OWNER] {
 	return q.
 		SetDialect(owner.getDialect()).
 		Table(c.OwnerTable).Select(owner.Columns(true)...).
-		Where(c.ForeignColumnName, ownerID)
+		Where(c.ForeignColumnName, ownerID).
+		setSchema(owner)
 
 }
 
@@ -433,16 +785,29 @@ func BelongsToMany[OWNER Entity](property Entity) *QueryBuilder[OWNER] {
 	if !ok {
 		q.err = fmt.Errorf("wrong config passed for HasMany")
 	}
+	ids := NewQueryBuilder[OWNER]().
+		Table(c.IntermediateTable).
+		Select(c.IntermediateOwnerID).
+		Where(c.IntermediatePropertyID, genericGetPKValue(property))
 	return q.
+		SetDialect(getSchemaFor(*out).getDialect()).
 		Select(getSchemaFor(*out).Columns(true)...).
 		Table(getSchemaFor(*out).Table).
-		WhereIn(c.OwnerLookupColumn, Raw(fmt.Sprintf(`SELECT %s FROM %s WHERE %s = ?`,
-			c.IntermediateOwnerID,
-			c.IntermediateTable, c.IntermediatePropertyID), genericGetPKValue(property)))
+		WhereIn(c.OwnerLookupColumn, ids).
+		setSchema(getSchemaFor(*out))
 }
 
 // Add adds `items` to `to` using relations defined between items and to in ConfigureEntity method of `to`.
 func Add(to Entity, items ...Entity) error {
+	return add(context.Background(), nil, to, items...)
+}
+
+// AddContext is like Add but uses given context for executing query.
+func AddContext(ctx context.Context, to Entity, items ...Entity) error {
+	return add(ctx, nil, to, items...)
+}
+
+func add(ctx context.Context, tx *Tx, to Entity, items ...Entity) error {
 	if len(items) == 0 {
 		return nil
 	}
@@ -454,9 +819,9 @@ func Add(to Entity, items ...Entity) error {
 	}
 	switch c.(type) {
 	case HasManyConfig:
-		return addProperty(to, items...)
+		return addProperty(ctx, tx, to, items...)
 	case HasOneConfig:
-		return addProperty(to, items[0])
+		return addProperty(ctx, tx, to, items[0])
 	case BelongsToManyConfig:
 		return fmt.Errorf("adding to a belongs to many relation is not implemented yet")
 	default:
@@ -465,7 +830,7 @@ func Add(to Entity, items ...Entity) error {
 }
 
 // addHasMany(Post, comments)
-func addProperty(to Entity, items ...Entity) error {
+func addProperty(ctx context.Context, tx *Tx, to Entity, items ...Entity) error {
 	var lastTable string
 	for _, obj := range items {
 		s := getSchemaFor(obj)
@@ -479,11 +844,12 @@ func addProperty(to Entity, items ...Entity) error {
 	}
 	i := insertStmt{
 		PlaceHolderGenerator: getSchemaFor(to).getDialect().PlaceHolderGenerator,
+		Dialect:              getSchemaFor(to).getDialect(),
 		Table:                getSchemaFor(items[0]).getTable(),
 	}
 	ownerPKIdx := -1
 	ownerPKName := getSchemaFor(items[0]).relations[getSchemaFor(to).Table].(BelongsToConfig).LocalForeignKey
-	for idx, col := range getSchemaFor(items[0]).Columns(false) {
+	for idx, col := range getSchemaFor(items[0]).columnNames(false) {
 		if col == ownerPKName {
 			ownerPKIdx = idx
 		}
@@ -491,7 +857,7 @@ func addProperty(to Entity, items ...Entity) error {
 
 	ownerPK := genericGetPKValue(to)
 	if ownerPKIdx != -1 {
-		cols := getSchemaFor(items[0]).Columns(false)
+		cols := getSchemaFor(items[0]).columnNames(false)
 		i.Columns = append(i.Columns, cols...)
 		// Owner PK is present in the items struct
 		for _, item := range items {
@@ -504,7 +870,7 @@ func addProperty(to Entity, items ...Entity) error {
 		}
 	} else {
 		ownerPKIdx = 0
-		cols := getSchemaFor(items[0]).Columns(false)
+		cols := getSchemaFor(items[0]).columnNames(false)
 		cols = append(cols[:ownerPKIdx+1], cols[ownerPKIdx:]...)
 		cols[ownerPKIdx] = getSchemaFor(items[0]).relations[getSchemaFor(to).Table].(BelongsToConfig).LocalForeignKey
 		i.Columns = append(i.Columns, cols...)
@@ -521,7 +887,11 @@ func addProperty(to Entity, items ...Entity) error {
 
 	q, args := i.ToSql()
 
-	_, err := getConnectionFor(items[0]).Connection.Exec(q, args...)
+	exec, err := getSchemaFor(items[0]).getExecutor(tx)
+	if err != nil {
+		return err
+	}
+	_, err = exec.exec(ctx, q, args...)
 	if err != nil {
 		return err
 	}
@@ -534,24 +904,45 @@ func addProperty(to Entity, items ...Entity) error {
 func Query[E Entity]() *QueryBuilder[E] {
 	q := NewQueryBuilder[E]()
 	s := getSchemaFor(*new(E))
-	q.SetDialect(s.getDialect()).Table(s.Table)
+	q.SetDialect(s.getDialect()).Table(s.Table).setSchema(s)
 	return q
 }
 
 // ExecRaw executes given query string and arguments on given type parameter database connection.
 func ExecRaw[E Entity](q string, args ...interface{}) (int64, int64, error) {
+	return execRaw[E](context.Background(), nil, q, args...)
+}
+
+// ExecRawContext is like ExecRaw but uses given context for executing query.
+func ExecRawContext[E Entity](ctx context.Context, q string, args ...interface{}) (int64, int64, error) {
+	return execRaw[E](ctx, nil, q, args...)
+}
+
+// ExecRawTx is like ExecRaw but runs inside given transaction.
+func ExecRawTx[E Entity](tx *Tx, q string, args ...interface{}) (int64, int64, error) {
+	return execRaw[E](tx.ctx, tx, q, args...)
+}
+
+func execRaw[E Entity](ctx context.Context, tx *Tx, q string, args ...interface{}) (int64, int64, error) {
 	e := new(E)
 
-	res, err := getSchemaFor(*e).getSQLDB().Exec(q, args...)
+	exec, err := getSchemaFor(*e).getExecutor(tx)
 	if err != nil {
 		return 0, 0, err
 	}
-
-	id, err := res.LastInsertId()
+	res, err := exec.exec(ctx, q, args...)
 	if err != nil {
 		return 0, 0, err
 	}
 
+	// some drivers like lib/pq cannot report inserted ID, it's zero for them.
+	var id int64
+	if getSchemaFor(*e).getDialect().SupportsLastInsertID {
+		if id, err = res.LastInsertId(); err != nil {
+			return 0, 0, err
+		}
+	}
+
 	affected, err := res.RowsAffected()
 	if err != nil {
 		return 0, 0, err
@@ -562,8 +953,26 @@ func ExecRaw[E Entity](q string, args ...interface{}) (int64, int64, error) {
 
 // QueryRaw queries given query string and arguments on given type parameter database connection.
 func QueryRaw[OUTPUT Entity](q string, args ...interface{}) ([]OUTPUT, error) {
+	return queryRaw[OUTPUT](context.Background(), nil, q, args...)
+}
+
+// QueryRawContext is like QueryRaw but uses given context for executing query.
+func QueryRawContext[OUTPUT Entity](ctx context.Context, q string, args ...interface{}) ([]OUTPUT, error) {
+	return queryRaw[OUTPUT](ctx, nil, q, args...)
+}
+
+// QueryRawTx is like QueryRaw but runs inside given transaction.
+func QueryRawTx[OUTPUT Entity](tx *Tx, q string, args ...interface{}) ([]OUTPUT, error) {
+	return queryRaw[OUTPUT](tx.ctx, tx, q, args...)
+}
+
+func queryRaw[OUTPUT Entity](ctx context.Context, tx *Tx, q string, args ...interface{}) ([]OUTPUT, error) {
 	o := new(OUTPUT)
-	rows, err := getSchemaFor(*o).getSQLDB().Query(q, args...)
+	exec, err := getSchemaFor(*o).getExecutor(tx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := exec.query(ctx, q, args...)
 	if err != nil {
 		return nil, err
 	}
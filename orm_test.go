@@ -1,8 +1,12 @@
 package orm_test
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/golobby/orm"
 	"github.com/stretchr/testify/assert"
@@ -87,6 +91,26 @@ func (c Category) Posts() ([]Post, error) {
 	return orm.BelongsToMany[Post](c).All()
 }
 
+type Ticket struct {
+	ID     int64
+	Title  string
+	Status string `orm:"generated=true"`
+}
+
+func (t Ticket) ConfigureEntity(e *orm.EntityConfigurator) {
+	e.Table("tickets")
+}
+
+type ArchivedComment struct {
+	ID     int64
+	PostID int64
+	Body   string
+}
+
+func (c ArchivedComment) ConfigureEntity(e *orm.EntityConfigurator) {
+	e.Table("archived_comments")
+}
+
 // enough models let's test
 // Entities is mandatory
 // Errors should be carried
@@ -95,7 +119,7 @@ func setup(t *testing.T) {
 	err := orm.SetupConnection(orm.ConnectionConfig{
 		Driver:   "sqlite3",
 		DSN:      ":memory:",
-		Entities: []orm.Entity{&Post{}, &Comment{}, &Category{}, &HeaderPicture{}},
+		Entities: []orm.Entity{&Post{}, &Comment{}, &Category{}, &HeaderPicture{}, &Ticket{}, &ArchivedComment{}},
 	})
 	_, err = orm.GetConnection("default").Connection.Exec(`CREATE TABLE IF NOT EXISTS posts (id INTEGER PRIMARY KEY, body text, created_at TIMESTAMP, updated_at TIMESTAMP, deleted_at TIMESTAMP)`)
 	_, err = orm.GetConnection("default").Connection.Exec(`CREATE TABLE IF NOT EXISTS emails (id INTEGER PRIMARY KEY, post_id INTEGER, email text)`)
@@ -103,6 +127,8 @@ func setup(t *testing.T) {
 	_, err = orm.GetConnection("default").Connection.Exec(`CREATE TABLE IF NOT EXISTS comments (id INTEGER PRIMARY KEY, post_id INTEGER, body text)`)
 	_, err = orm.GetConnection("default").Connection.Exec(`CREATE TABLE IF NOT EXISTS categories (id INTEGER PRIMARY KEY, title text)`)
 	_, err = orm.GetConnection("default").Connection.Exec(`CREATE TABLE IF NOT EXISTS post_categories (post_id INTEGER, category_id INTEGER, PRIMARY KEY(post_id, category_id))`)
+	_, err = orm.GetConnection("default").Connection.Exec(`CREATE TABLE IF NOT EXISTS tickets (id INTEGER PRIMARY KEY, title TEXT, status TEXT NOT NULL DEFAULT 'open')`)
+	_, err = orm.GetConnection("default").Connection.Exec(`CREATE TABLE IF NOT EXISTS archived_comments (id INTEGER PRIMARY KEY, post_id INTEGER, body text)`)
 	assert.NoError(t, err)
 }
 
@@ -153,8 +179,49 @@ func TestInsertAll(t *testing.T) {
 	var counter int
 	assert.NoError(t, orm.GetConnection("default").Connection.QueryRow(`SELECT count(id) FROM posts`).Scan(&counter))
 	assert.Equal(t, 3, counter)
+	assert.Equal(t, []int64{1, 2, 3}, []int64{post1.ID, post2.ID, post3.ID})
+
+	t.Run("chunks", func(t *testing.T) {
+		// comments have two columns to insert so sqlite limit of 999 placeholders fits 499 of them in a query.
+		var comments []orm.Entity
+		for i := 0; i < 1200; i++ {
+			comments = append(comments, &Comment{PostID: 1, Body: fmt.Sprint(i)})
+		}
+		assert.NoError(t, orm.Insert(comments...))
+		count, err := orm.Query[Comment]().Count()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1200), count)
+		for i, c := range comments {
+			assert.Equal(t, int64(i+1), c.(*Comment).ID)
+		}
+		last, err := orm.Find[Comment](1200)
+		assert.NoError(t, err)
+		assert.Equal(t, "1199", last.Body)
+	})
+}
+func TestInsertRowByRow(t *testing.T) {
+	setup(t)
+	// a dialect like MySQL with interleaved auto increment lock mode that cannot trust IDs of multi-row inserts.
+	dialect := *orm.Dialects.SQLite3
+	dialect.ConsecutiveInsertIDs = false
+	dialect.Detect = nil
+	err := orm.SetupConnection(orm.ConnectionConfig{
+		DB:       orm.GetConnection("default").Connection,
+		Dialect:  &dialect,
+		Entities: []orm.Entity{&Post{}, &Comment{}, &Category{}, &HeaderPicture{}},
+	})
+	assert.NoError(t, err)
+	_, err = orm.GetConnection("default").Connection.Exec(`INSERT INTO comments (id, post_id, body) VALUES (5, 1, 'existing')`)
+	assert.NoError(t, err)
 
+	first, second, third := &Comment{PostID: 1, Body: "first"}, &Comment{PostID: 1, Body: "second"}, &Comment{PostID: 1, Body: "third"}
+	assert.NoError(t, orm.Insert(first, second, third))
+	assert.Equal(t, []int64{6, 7, 8}, []int64{first.ID, second.ID, third.ID})
+	comment, err := orm.Find[Comment](7)
+	assert.NoError(t, err)
+	assert.Equal(t, "second", comment.Body)
 }
+
 func TestUpdateORM(t *testing.T) {
 	setup(t)
 	post := &Post{
@@ -184,13 +251,118 @@ func TestDeleteORM(t *testing.T) {
 	assert.Equal(t, int64(1), post.ID)
 
 	assert.NoError(t, orm.Delete(post))
+	assert.True(t, post.DeletedAt.Valid)
 
 	var count int
+	assert.NoError(t,
+		orm.GetConnection("default").Connection.QueryRow(`SELECT count(id) FROM posts where id = ? AND deleted_at IS NOT NULL`, post.ID).Scan(&count))
+
+	assert.Equal(t, 1, count)
+
+	assert.NoError(t, orm.ForceDelete(post))
 	assert.NoError(t,
 		orm.GetConnection("default").Connection.QueryRow(`SELECT count(id) FROM posts where id = ?`, post.ID).Scan(&count))
 
 	assert.Equal(t, 0, count)
 }
+
+func TestSoftDelete(t *testing.T) {
+	t.Run("soft deleted entities are not queried", func(t *testing.T) {
+		setup(t)
+		post := &Post{BodyText: "deleted"}
+		assert.NoError(t, orm.Save(post))
+		assert.NoError(t, orm.Save(&Post{BodyText: "alive"}))
+		assert.NoError(t, orm.Delete(post))
+
+		found, err := orm.Find[Post](post.ID)
+		assert.NoError(t, err)
+		assert.Zero(t, found.ID)
+
+		posts, err := orm.Query[Post]().All()
+		assert.NoError(t, err)
+		assert.Len(t, posts, 1)
+		assert.Equal(t, "alive", posts[0].BodyText)
+
+		count, err := orm.Query[Post]().WithTrashed().Count()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 2, count)
+
+		posts, err = orm.Query[Post]().OnlyTrashed().All()
+		assert.NoError(t, err)
+		assert.Len(t, posts, 1)
+		assert.Equal(t, "deleted", posts[0].BodyText)
+	})
+
+	t.Run("soft deleted entities are not queried in relations", func(t *testing.T) {
+		setup(t)
+		post := &Post{BodyText: "deleted"}
+		assert.NoError(t, orm.Save(post))
+		comment := &Comment{PostID: post.ID, Body: "comment"}
+		assert.NoError(t, orm.Save(comment))
+		assert.NoError(t, orm.Delete(post))
+
+		owner, err := orm.BelongsTo[Post](comment).One()
+		assert.NoError(t, err)
+		assert.Zero(t, owner.ID)
+
+		owner, err = orm.BelongsTo[Post](comment).WithTrashed().One()
+		assert.NoError(t, err)
+		assert.Equal(t, post.ID, owner.ID)
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		setup(t)
+		post := &Post{BodyText: "deleted"}
+		assert.NoError(t, orm.Save(post))
+		assert.NoError(t, orm.Delete(post))
+		assert.NoError(t, orm.Restore(post))
+		assert.False(t, post.DeletedAt.Valid)
+
+		count, err := orm.Query[Post]().Count()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 1, count)
+	})
+
+	t.Run("using query builder", func(t *testing.T) {
+		setup(t)
+		assert.NoError(t, orm.Save(&Post{BodyText: "1"}))
+		assert.NoError(t, orm.Save(&Post{BodyText: "2"}))
+
+		_, err := orm.Query[Post]().Where("body", "1").Delete()
+		assert.NoError(t, err)
+		count, err := orm.Query[Post]().Count()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 1, count)
+
+		_, err = orm.Query[Post]().Where("body", "1").Restore()
+		assert.NoError(t, err)
+		count, err = orm.Query[Post]().Count()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 2, count)
+
+		_, err = orm.Query[Post]().Where("body", "1").ForceDelete()
+		assert.NoError(t, err)
+		count, err = orm.Query[Post]().WithTrashed().Count()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 1, count)
+	})
+
+	t.Run("force delete removes soft deleted rows", func(t *testing.T) {
+		setup(t)
+		post := &Post{BodyText: "deleted"}
+		assert.NoError(t, orm.Save(post))
+		assert.NoError(t, orm.Delete(post))
+
+		res, err := orm.Query[Post]().Where("id", post.ID).ForceDelete()
+		assert.NoError(t, err)
+		affected, err := res.RowsAffected()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 1, affected)
+		count, err := orm.Query[Post]().WithTrashed().Count()
+		assert.NoError(t, err)
+		assert.Zero(t, count)
+	})
+}
 func TestAdd(t *testing.T) {
 	setup(t)
 	post := &Post{
@@ -454,3 +626,752 @@ func TestQuery(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestTransaction(t *testing.T) {
+	t.Run("commit when no error returned", func(t *testing.T) {
+		setup(t)
+		err := orm.Transaction(func(tx *orm.Tx) error {
+			post := &Post{BodyText: "body 1"}
+			if err := tx.Insert(post); err != nil {
+				return err
+			}
+			return tx.Add(post, &Comment{PostID: post.ID, Body: "comment 1"})
+		})
+		assert.NoError(t, err)
+
+		count, err := orm.Query[Comment]().Count()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 1, count)
+	})
+
+	t.Run("rollback when error returned", func(t *testing.T) {
+		setup(t)
+		err := orm.Transaction(func(tx *orm.Tx) error {
+			if err := tx.Insert(&Post{BodyText: "body 1"}); err != nil {
+				return err
+			}
+			posts, err := orm.Query[Post]().WithTx(tx).All()
+			assert.NoError(t, err)
+			assert.Len(t, posts, 1)
+			return fmt.Errorf("checkout failed")
+		})
+		assert.EqualError(t, err, "checkout failed")
+
+		count, err := orm.Query[Post]().Count()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, count)
+	})
+
+	t.Run("rollback when panicked", func(t *testing.T) {
+		setup(t)
+		assert.Panics(t, func() {
+			_ = orm.Transaction(func(tx *orm.Tx) error {
+				assert.NoError(t, tx.Insert(&Post{BodyText: "body 1"}))
+				panic("something bad happened")
+			})
+		})
+
+		count, err := orm.Query[Post]().Count()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, count)
+	})
+
+	t.Run("manual begin and commit", func(t *testing.T) {
+		setup(t)
+		tx, err := orm.Begin()
+		assert.NoError(t, err)
+		post := &Post{BodyText: "body 1"}
+		assert.NoError(t, tx.Save(post))
+		post.BodyText = "body 2"
+		assert.NoError(t, tx.Save(post))
+		found, err := orm.FindTx[Post](tx, post.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "body 2", found.BodyText)
+		assert.NoError(t, tx.Commit())
+		assert.Error(t, tx.Commit())
+
+		found, err = orm.Find[Post](post.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "body 2", found.BodyText)
+	})
+}
+
+func TestContext(t *testing.T) {
+	setup(t)
+	assert.NoError(t, orm.Save(&Post{BodyText: "body 1"}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := orm.FindContext[Post](ctx, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = orm.Query[Post]().WithContext(ctx).All()
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = orm.Query[Post]().WithContext(ctx).Count()
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = orm.Query[Post]().WithContext(ctx).WherePK(1).Update(orm.KV{"body": "body 2"})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, _, err = orm.ExecRawContext[Post](ctx, `DELETE FROM posts`)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = orm.QueryRawContext[Post](ctx, `SELECT * FROM posts`)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	assert.ErrorIs(t, orm.InsertContext(ctx, &Post{BodyText: "body 2"}), context.Canceled)
+	assert.ErrorIs(t, orm.TransactionContext(ctx, func(tx *orm.Tx) error { return nil }), context.Canceled)
+
+	post, err := orm.FindContext[Post](context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "body 1", post.BodyText)
+}
+
+func TestNestedTransaction(t *testing.T) {
+	setup(t)
+	err := orm.Transaction(func(tx *orm.Tx) error {
+		assert.NoError(t, tx.Insert(&Post{BodyText: "outer"}))
+
+		err := tx.Transaction(func(tx *orm.Tx) error {
+			assert.NoError(t, tx.Insert(&Post{BodyText: "inner that fails"}))
+			return fmt.Errorf("inner failed")
+		})
+		assert.EqualError(t, err, "inner failed")
+
+		return tx.Transaction(func(tx *orm.Tx) error {
+			if err := tx.Insert(&Post{BodyText: "inner"}); err != nil {
+				return err
+			}
+			return tx.Transaction(func(tx *orm.Tx) error {
+				return tx.Insert(&Post{BodyText: "innermost"})
+			})
+		})
+	})
+	assert.NoError(t, err)
+
+	posts, err := orm.Query[Post]().All()
+	assert.NoError(t, err)
+	var bodies []string
+	for _, post := range posts {
+		bodies = append(bodies, post.BodyText)
+	}
+	assert.Equal(t, []string{"outer", "inner", "innermost"}, bodies)
+}
+
+func TestReadReplicas(t *testing.T) {
+	open := func(body string) *sql.DB {
+		db, err := sql.Open("sqlite3", ":memory:")
+		assert.NoError(t, err)
+		db.SetMaxOpenConns(1)
+		_, err = db.Exec(`CREATE TABLE posts (id INTEGER PRIMARY KEY, body text, created_at TIMESTAMP, updated_at TIMESTAMP, deleted_at TIMESTAMP)`)
+		assert.NoError(t, err)
+		_, err = db.Exec(`INSERT INTO posts (body) VALUES (?)`, body)
+		assert.NoError(t, err)
+		return db
+	}
+	primary := open("primary")
+	err := orm.SetupConnection(orm.ConnectionConfig{
+		DB:       primary,
+		Dialect:  orm.Dialects.SQLite3,
+		Replicas: []*sql.DB{open("replica 1"), open("replica 2")},
+		Entities: []orm.Entity{&Post{}},
+	})
+	assert.NoError(t, err)
+
+	t.Run("reads are load balanced between replicas", func(t *testing.T) {
+		first, err := orm.Find[Post](1)
+		assert.NoError(t, err)
+		second, err := orm.Query[Post]().WherePK(1).First()
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"replica 1", "replica 2"}, []string{first.BodyText, second.BodyText})
+	})
+
+	t.Run("writes go to primary", func(t *testing.T) {
+		assert.NoError(t, orm.Save(&Post{BodyText: "new post"}))
+		var count int
+		assert.NoError(t, primary.QueryRow(`SELECT COUNT(id) FROM posts`).Scan(&count))
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("reading from primary can be forced", func(t *testing.T) {
+		post, err := orm.FindContext[Post](orm.UsePrimary(context.Background()), 2)
+		assert.NoError(t, err)
+		assert.Equal(t, "new post", post.BodyText)
+
+		count, err := orm.Query[Post]().UsePrimary().Count()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 2, count)
+	})
+
+	t.Run("transactions stick to primary", func(t *testing.T) {
+		assert.NoError(t, orm.Transaction(func(tx *orm.Tx) error {
+			post, err := orm.FindTx[Post](tx, 1)
+			assert.NoError(t, err)
+			assert.Equal(t, "primary", post.BodyText)
+			return nil
+		}))
+	})
+
+	t.Run("replica DSNs are opened using driver of dialect when driver is not set", func(t *testing.T) {
+		err := orm.SetupConnection(orm.ConnectionConfig{
+			DB:          primary,
+			Dialect:     orm.Dialects.SQLite3,
+			ReplicaDSNs: []string{":memory:"},
+			Entities:    []orm.Entity{&Post{}},
+		})
+		assert.NoError(t, err)
+		assert.Len(t, orm.GetConnection("default").Replicas, 1)
+	})
+}
+
+func TestUpdatedAt(t *testing.T) {
+	old := sql.NullTime{Time: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+	updatedAtOf := func(t *testing.T, id int64) time.Time {
+		var updatedAt sql.NullTime
+		assert.NoError(t, orm.GetConnection("default").Connection.QueryRow(`SELECT updated_at FROM posts WHERE id = ?`, id).Scan(&updatedAt))
+		return updatedAt.Time
+	}
+	insertOld := func(t *testing.T) *Post {
+		post := &Post{BodyText: "body", CreatedAt: old, UpdatedAt: old}
+		assert.NoError(t, orm.InsertContext(orm.WithoutTimestamps(context.Background()), post))
+		assert.True(t, updatedAtOf(t, post.ID).Equal(old.Time))
+		return post
+	}
+
+	t.Run("update sets updated at", func(t *testing.T) {
+		setup(t)
+		post := insertOld(t)
+		post.BodyText = "new body"
+		assert.NoError(t, orm.Save(post))
+		assert.True(t, post.UpdatedAt.Time.After(old.Time))
+		assert.True(t, updatedAtOf(t, post.ID).After(old.Time))
+	})
+
+	t.Run("query builder update sets updated at", func(t *testing.T) {
+		setup(t)
+		post := insertOld(t)
+		_, err := orm.Query[Post]().WherePK(post.ID).Update(orm.KV{"body": "new body"})
+		assert.NoError(t, err)
+		assert.True(t, updatedAtOf(t, post.ID).After(old.Time))
+	})
+
+	t.Run("opting out of timestamps", func(t *testing.T) {
+		setup(t)
+		post := insertOld(t)
+		post.BodyText = "new body"
+		assert.NoError(t, orm.UpdateContext(orm.WithoutTimestamps(context.Background()), post))
+		assert.True(t, updatedAtOf(t, post.ID).Equal(old.Time))
+
+		_, err := orm.Query[Post]().WherePK(post.ID).WithoutTimestamps().Update(orm.KV{"body": "newer body"})
+		assert.NoError(t, err)
+		assert.True(t, updatedAtOf(t, post.ID).Equal(old.Time))
+	})
+}
+
+func TestCreateTables(t *testing.T) {
+	err := orm.SetupConnection(orm.ConnectionConfig{
+		Driver:   "sqlite3",
+		DSN:      ":memory:",
+		Entities: []orm.Entity{&Post{}, &Comment{}},
+	})
+	assert.NoError(t, err)
+
+	q, err := orm.CreateTableSQL[Comment]()
+	assert.NoError(t, err)
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS "comments" ("id" INTEGER PRIMARY KEY AUTOINCREMENT, "post_id" INTEGER NOT NULL, "body" TEXT NOT NULL, FOREIGN KEY ("post_id") REFERENCES "posts"("id"))`, q)
+
+	assert.NoError(t, orm.CreateTables(&Comment{}, &Post{}))
+
+	post := &Post{BodyText: "body"}
+	assert.NoError(t, orm.Save(post))
+	assert.NoError(t, orm.Save(&Comment{PostID: post.ID, Body: "comment"}))
+	comments, err := orm.HasMany[Comment](post).All()
+	assert.NoError(t, err)
+	assert.Len(t, comments, 1)
+}
+
+func TestIntrospection(t *testing.T) {
+	err := orm.SetupConnection(orm.ConnectionConfig{
+		Driver:   "sqlite3",
+		DSN:      ":memory:",
+		Entities: []orm.Entity{&Post{}, &Comment{}},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, orm.CreateTables(&Post{}, &Comment{}))
+	_, err = orm.GetConnection("default").Connection.Exec(`CREATE TABLE tags (name TEXT DEFAULT 'none')`)
+	assert.NoError(t, err)
+
+	tables, err := orm.GetConnection("default").Tables()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"comments", "posts", "tags"}, tables)
+
+	columns, err := orm.GetConnection("default").Columns("comments")
+	assert.NoError(t, err)
+	assert.Equal(t, []*orm.Column{
+		{Name: "id", Type: "INTEGER", IsPK: true},
+		{Name: "post_id", Type: "INTEGER"},
+		{Name: "body", Type: "TEXT"},
+	}, columns)
+
+	columns, err = orm.GetConnection("default").Columns("tags")
+	assert.NoError(t, err)
+	assert.Equal(t, []*orm.Column{
+		{Name: "name", Type: "TEXT", Nullable: true, Default: sql.NullString{String: "'none'", Valid: true}},
+	}, columns)
+}
+
+func TestValidate(t *testing.T) {
+	open := func(ddl ...string) *sql.DB {
+		db, err := sql.Open("sqlite3", ":memory:")
+		assert.NoError(t, err)
+		db.SetMaxOpenConns(1)
+		for _, q := range ddl {
+			_, err = db.Exec(q)
+			assert.NoError(t, err)
+		}
+		return db
+	}
+	t.Run("entities matching the database", func(t *testing.T) {
+		err := orm.SetupConnection(orm.ConnectionConfig{
+			DB: open(
+				`CREATE TABLE posts (id INTEGER PRIMARY KEY, body text)`,
+				`CREATE TABLE comments (id INTEGER PRIMARY KEY, post_id INTEGER, body text)`,
+				`CREATE TABLE categories (id INTEGER PRIMARY KEY, title VARCHAR(255))`,
+				`CREATE TABLE post_categories (post_id INTEGER, category_id INTEGER)`,
+			),
+			Dialect:  orm.Dialects.SQLite3,
+			Entities: []orm.Entity{&Comment{}, &Category{}},
+			Validate: true,
+		})
+		assert.NoError(t, err)
+	})
+	t.Run("all mismatches are reported", func(t *testing.T) {
+		err := orm.SetupConnection(orm.ConnectionConfig{
+			DB: open(
+				`CREATE TABLE posts (id INTEGER PRIMARY KEY, body text)`,
+				`CREATE TABLE comments (id INTEGER PRIMARY KEY, body BLOB)`,
+			),
+			Dialect:  orm.Dialects.SQLite3,
+			Entities: []orm.Entity{&Comment{}, &Category{}},
+			Validate: true,
+		})
+		var validationErr *orm.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, []string{
+			"table categories does not exist",
+			"relation of categories: table post_categories does not exist",
+			"column comments.post_id does not exist",
+			"column comments.body has type BLOB which is not compatible with string",
+			"relation of comments: column comments.post_id does not exist",
+		}, validationErr.Problems)
+	})
+	t.Run("invalid connection is not registered", func(t *testing.T) {
+		err := orm.SetupConnection(orm.ConnectionConfig{
+			Name:     "invalid",
+			DB:       open(),
+			Dialect:  orm.Dialects.SQLite3,
+			Entities: []orm.Entity{&Comment{}},
+			Validate: true,
+		})
+		assert.Error(t, err)
+		assert.Nil(t, orm.GetConnection("invalid"))
+	})
+}
+
+type Subscriber struct {
+	ID       int64
+	Email    string `orm:"unique=true"`
+	Name     string `orm:"index=subscribers_name_city"`
+	City     string `orm:"index=subscribers_name_city default=''"`
+	Verified bool
+}
+
+func (s Subscriber) ConfigureEntity(e *orm.EntityConfigurator) {
+	e.Table("subscribers")
+}
+
+func TestAutoMigrate(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(`CREATE TABLE subscribers (id INTEGER PRIMARY KEY, email TEXT, verified TEXT, legacy TEXT)`)
+	assert.NoError(t, err)
+	err = orm.SetupConnection(orm.ConnectionConfig{
+		DB:       db,
+		Dialect:  orm.Dialects.SQLite3,
+		Entities: []orm.Entity{&Subscriber{}, &Category{}},
+	})
+	assert.NoError(t, err)
+
+	plans, err := orm.AutoMigrateWithConfig(orm.MigrateConfig{DryRun: true}, &Subscriber{}, &Category{})
+	assert.NoError(t, err)
+	assert.Equal(t, []*orm.MigrationPlan{{
+		Connection: "default",
+		Dialect:    "sqlite3",
+		Statements: []string{
+			`ALTER TABLE "subscribers" ADD COLUMN "name" TEXT`,
+			`ALTER TABLE "subscribers" ADD COLUMN "city" TEXT NOT NULL DEFAULT ''`,
+			`CREATE UNIQUE INDEX "subscribers_email_unique" ON "subscribers" ("email")`,
+			`CREATE INDEX "subscribers_name_city" ON "subscribers" ("name", "city")`,
+			`CREATE TABLE IF NOT EXISTS "categories" ("id" INTEGER PRIMARY KEY AUTOINCREMENT, "title" TEXT NOT NULL)`,
+		},
+		Destructive: []string{`ALTER TABLE "subscribers" DROP COLUMN "legacy"`},
+		Warnings:    []string{`sqlite3 does not support changing type of column subscribers.verified from TEXT to BOOLEAN`},
+	}}, plans)
+	tables, err := orm.GetConnection("default").Tables()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"subscribers"}, tables)
+
+	_, err = orm.AutoMigrate(&Subscriber{}, &Category{})
+	assert.NoError(t, err)
+	tables, err = orm.GetConnection("default").Tables()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"categories", "subscribers"}, tables)
+	indexes, err := orm.GetConnection("default").Indexes("subscribers")
+	assert.NoError(t, err)
+	assert.Equal(t, []*orm.Index{
+		{Name: "subscribers_email_unique", Columns: []string{"email"}, Unique: true},
+		{Name: "subscribers_name_city", Columns: []string{"name", "city"}},
+	}, indexes)
+	columns, err := orm.GetConnection("default").Columns("subscribers")
+	assert.NoError(t, err)
+	assert.Len(t, columns, 6)
+
+	plans, err = orm.AutoMigrateWithConfig(orm.MigrateConfig{DryRun: true}, &Subscriber{}, &Category{})
+	assert.NoError(t, err)
+	assert.Empty(t, plans[0].Statements)
+	assert.Equal(t, []string{`ALTER TABLE "subscribers" DROP COLUMN "legacy"`}, plans[0].Destructive)
+}
+
+func TestMigrator(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	err = orm.SetupConnection(orm.ConnectionConfig{DB: db, Dialect: orm.Dialects.SQLite3})
+	assert.NoError(t, err)
+
+	m, err := orm.NewMigrator("")
+	assert.NoError(t, err)
+	m.Add("001", "create_posts", func(ctx context.Context, db orm.SQLExecutor) error {
+		_, err := db.ExecContext(ctx, `CREATE TABLE posts (id INTEGER PRIMARY KEY, body TEXT)`)
+		return err
+	}, func(ctx context.Context, db orm.SQLExecutor) error {
+		_, err := db.ExecContext(ctx, `DROP TABLE posts`)
+		return err
+	})
+	assert.NoError(t, m.AddFS(fstest.MapFS{
+		"migrations/002_create_comments.up.sql":   {Data: []byte(`CREATE TABLE comments (id INTEGER PRIMARY KEY, post_id INTEGER, body TEXT);`)},
+		"migrations/002_create_comments.down.sql": {Data: []byte(`DROP TABLE comments;`)},
+		"migrations/README.md":                    {Data: []byte(`not a migration`)},
+	}, "migrations"))
+	tables := func() []string {
+		tables, err := orm.GetConnection("default").Tables()
+		assert.NoError(t, err)
+		return tables
+	}
+
+	assert.EqualError(t, m.CheckPending(), "there are 2 pending migrations: 001_create_posts, 002_create_comments")
+	assert.NoError(t, m.Up())
+	assert.NoError(t, m.CheckPending())
+	assert.Equal(t, []string{"comments", "posts", "schema_migrations"}, tables())
+
+	assert.NoError(t, m.Down(1))
+	assert.Equal(t, []string{"posts", "schema_migrations"}, tables())
+	statuses, err := m.Status()
+	assert.NoError(t, err)
+	assert.Equal(t, []orm.MigrationStatus{
+		{Version: "001", Name: "create_posts", Applied: true},
+		{Version: "002", Name: "create_comments", Applied: false},
+	}, statuses)
+
+	assert.NoError(t, m.Up())
+	assert.NoError(t, m.Redo())
+	assert.Equal(t, []string{"comments", "posts", "schema_migrations"}, tables())
+
+	t.Run("failed migration is rolled back", func(t *testing.T) {
+		m.AddSQL("003", "broken", `CREATE TABLE tags (id INTEGER PRIMARY KEY); INSERT INTO missing VALUES (1);`, "")
+		assert.Error(t, m.Up())
+		assert.Equal(t, []string{"comments", "posts", "schema_migrations"}, tables())
+		assert.Error(t, m.CheckPending())
+	})
+}
+
+func TestAggregates(t *testing.T) {
+	setup(t)
+	for _, c := range []Comment{{PostID: 1, Body: "a"}, {PostID: 1, Body: "b"}, {PostID: 2, Body: "c"}, {PostID: 4, Body: "d"}} {
+		c := c
+		assert.NoError(t, orm.Insert(&c))
+	}
+
+	count, err := orm.Query[Comment]().Count()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+
+	distinct, err := orm.Query[Comment]().CountDistinct("post_id")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), distinct)
+
+	sum, err := orm.Sum[Comment, int64](orm.Query[Comment](), "post_id")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), sum)
+
+	avg, err := orm.Avg[Comment, float64](orm.Query[Comment]().Where("post_id", "<", 4), "post_id")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(4)/3, avg)
+
+	min, err := orm.Min[Comment, string](orm.Query[Comment](), "body")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", min)
+
+	max, err := orm.Max[Comment, int64](orm.Query[Comment](), "post_id")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), max)
+
+	t.Run("aggregate of no rows is zero", func(t *testing.T) {
+		sum, err := orm.Sum[Comment, float64](orm.Query[Comment]().Where("post_id", 10), "post_id")
+		assert.NoError(t, err)
+		assert.Zero(t, sum)
+	})
+
+	t.Run("builder keeps its selection after an aggregate", func(t *testing.T) {
+		q := orm.Query[Comment]().Select("id", "body").Where("post_id", 1)
+		count, err := q.Count()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+		sql, _, err := q.ToSql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT "id","body" FROM "comments" WHERE "post_id" = ?`, sql)
+		comments, err := q.All()
+		assert.NoError(t, err)
+		assert.Len(t, comments, 2)
+		assert.Zero(t, comments[0].PostID)
+	})
+
+	t.Run("columns named like reserved words", func(t *testing.T) {
+		_, err := orm.GetConnection("default").Connection.Exec(`CREATE TABLE line_items (id INTEGER PRIMARY KEY, "order" INTEGER)`)
+		assert.NoError(t, err)
+		_, err = orm.GetConnection("default").Connection.Exec(`INSERT INTO line_items ("order") VALUES (1), (1), (2)`)
+		assert.NoError(t, err)
+		sum, err := orm.Sum[Comment, int64](orm.Query[Comment]().Table("line_items"), "order")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), sum)
+		distinct, err := orm.Query[Comment]().Table("line_items").CountDistinct("order")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), distinct)
+	})
+
+	t.Run("having", func(t *testing.T) {
+		comments, err := orm.Query[Comment]().
+			Select("MIN(id) AS id", "post_id", "MIN(body) AS body").
+			GroupBy("post_id").
+			Having("COUNT(id)", ">", 1).
+			OrHaving("post_id", 4).
+			OrderBy("post_id", orm.DESC).
+			All()
+		assert.NoError(t, err)
+		assert.Equal(t, []Comment{{ID: 4, PostID: 4, Body: "d"}, {ID: 1, PostID: 1, Body: "a"}}, comments)
+	})
+}
+
+func TestCompoundQueries(t *testing.T) {
+	setup(t)
+	for _, c := range []Comment{{PostID: 1, Body: "a"}, {PostID: 1, Body: "b"}, {PostID: 2, Body: "c"}, {PostID: 4, Body: "d"}} {
+		c := c
+		assert.NoError(t, orm.Insert(&c))
+	}
+
+	comments, err := orm.Query[Comment]().Where("post_id", 1).
+		Union(orm.Query[Comment]().Where("body", "d")).
+		UnionAll(orm.Query[Comment]().Where("id", 1)).
+		OrderBy("id", orm.DESC).
+		All()
+	assert.NoError(t, err)
+	assert.Equal(t, []Comment{{ID: 4, PostID: 4, Body: "d"}, {ID: 2, PostID: 1, Body: "b"}, {ID: 1, PostID: 1, Body: "a"}, {ID: 1, PostID: 1, Body: "a"}}, comments)
+
+	comments, err = orm.Query[Comment]().Where("post_id", "<", 4).
+		Except(orm.Query[Comment]().Where("post_id", 1)).
+		All()
+	assert.NoError(t, err)
+	assert.Equal(t, []Comment{{ID: 3, PostID: 2, Body: "c"}}, comments)
+
+	comment, err := orm.Query[Comment]().Where("post_id", 1).
+		Intersect(orm.Query[Comment]().Where("body", "b")).
+		One()
+	assert.NoError(t, err)
+	assert.Equal(t, Comment{ID: 2, PostID: 1, Body: "b"}, comment)
+}
+
+type Node struct {
+	ID       int64
+	ParentID int64
+	Name     string
+}
+
+func (n Node) ConfigureEntity(e *orm.EntityConfigurator) {
+	e.Table("nodes")
+}
+
+func TestWithRecursive(t *testing.T) {
+	err := orm.SetupConnection(orm.ConnectionConfig{
+		Driver:   "sqlite3",
+		DSN:      ":memory:",
+		Entities: []orm.Entity{&Node{}},
+	})
+	assert.NoError(t, err)
+	_, err = orm.GetConnection("default").Connection.Exec(`CREATE TABLE nodes (id INTEGER PRIMARY KEY, parent_id INTEGER, name TEXT)`)
+	assert.NoError(t, err)
+	for _, n := range []Node{{Name: "root"}, {ParentID: 1, Name: "a"}, {ParentID: 2, Name: "b"}, {ParentID: 1, Name: "c"}, {Name: "other"}} {
+		n := n
+		assert.NoError(t, orm.Insert(&n))
+	}
+
+	nodes, err := orm.Query[Node]().
+		WithRecursive("tree",
+			orm.Query[Node]().Where("id", 2),
+			orm.Query[Node]().Select("nodes.*").Join("tree", "tree.id", "nodes.parent_id")).
+		Table("tree").
+		OrderBy("id", orm.ASC).
+		All()
+	assert.NoError(t, err)
+	assert.Equal(t, []Node{{ID: 2, ParentID: 1, Name: "a"}, {ID: 3, ParentID: 2, Name: "b"}}, nodes)
+
+	count, err := orm.Query[Node]().
+		With("roots", orm.Query[Node]().Select("id").Where("parent_id", 0)).
+		WhereIn("parent_id", orm.Raw("SELECT id FROM roots")).
+		Count()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestUpsert(t *testing.T) {
+	setup(t)
+	first := &Post{ID: 1, BodyText: "first"}
+	assert.NoError(t, orm.Upsert(first, &Post{ID: 2, BodyText: "second"}))
+
+	updated := &Post{ID: 1, BodyText: "first updated"}
+	assert.NoError(t, orm.Upsert(updated, &Post{ID: 3, BodyText: "third"}))
+	posts, err := orm.Query[Post]().OrderBy("id", orm.ASC).All()
+	assert.NoError(t, err)
+	assert.Len(t, posts, 3)
+	assert.Equal(t, "first updated", posts[0].BodyText)
+	assert.Equal(t, first.CreatedAt.Time.Unix(), posts[0].CreatedAt.Time.Unix())
+	assert.Equal(t, updated.UpdatedAt.Time.Unix(), posts[0].UpdatedAt.Time.Unix())
+
+	t.Run("do nothing", func(t *testing.T) {
+		assert.NoError(t, orm.NewUpsert(&Post{ID: 2, BodyText: "ignored"}).DoNothing().Execute())
+		post, err := orm.Find[Post](2)
+		assert.NoError(t, err)
+		assert.Equal(t, "second", post.BodyText)
+	})
+
+	t.Run("custom conflict target", func(t *testing.T) {
+		_, err := orm.GetConnection("default").Connection.Exec(`CREATE UNIQUE INDEX categories_title ON categories (title)`)
+		assert.NoError(t, err)
+		assert.NoError(t, orm.Insert(&Category{Title: "go"}))
+		assert.NoError(t, orm.NewUpsert(&Category{Title: "go"}, &Category{Title: "rust"}).OnConflict("title").DoNothing().Execute())
+		categories, err := orm.Query[Category]().OrderBy("id", orm.ASC).All()
+		assert.NoError(t, err)
+		assert.Equal(t, []Category{{ID: 1, Title: "go"}, {ID: 2, Title: "rust"}}, categories)
+	})
+
+	t.Run("primary key should be set on all or none", func(t *testing.T) {
+		assert.Error(t, orm.Upsert(&Post{ID: 1}, &Post{}))
+	})
+
+	t.Run("conflict target is required without primary key", func(t *testing.T) {
+		assert.Error(t, orm.Upsert(&Post{BodyText: "duplicate"}))
+	})
+
+	t.Run("chunks", func(t *testing.T) {
+		// posts have five columns to upsert with primary key so sqlite limit of 999 placeholders fits 199 of them in a query.
+		var posts []orm.Entity
+		for i := 1; i <= 7000; i++ {
+			posts = append(posts, &Post{ID: int64(i), BodyText: fmt.Sprint(i)})
+		}
+		assert.NoError(t, orm.Upsert(posts...))
+		count, err := orm.Query[Post]().Count()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7000), count)
+		post, err := orm.Find[Post](1)
+		assert.NoError(t, err)
+		assert.Equal(t, "1", post.BodyText)
+	})
+}
+
+func TestInsertReturning(t *testing.T) {
+	setup(t)
+	// bundled sqlite is newer than 3.35 so it supports RETURNING.
+	assert.True(t, orm.GetConnection("default").Dialect.SupportsReturning)
+	assert.False(t, orm.Dialects.SQLite3.SupportsReturning)
+
+	first, second := &Ticket{Title: "first"}, &Ticket{Title: "second", Status: "ignored"}
+	assert.NoError(t, orm.Insert(first, second))
+	assert.Equal(t, &Ticket{ID: 1, Title: "first", Status: "open"}, first)
+	assert.Equal(t, &Ticket{ID: 2, Title: "second", Status: "open"}, second)
+
+	first.Title = "first updated"
+	first.Status = "closed"
+	assert.NoError(t, orm.Update(first))
+	ticket, err := orm.Find[Ticket](1)
+	assert.NoError(t, err)
+	assert.Equal(t, Ticket{ID: 1, Title: "first updated", Status: "open"}, ticket)
+
+	_, _, err = orm.ExecRaw[Ticket](`UPDATE tickets SET status = ? WHERE id = ?`, "closed", 1)
+	assert.NoError(t, err)
+}
+
+func TestInsertFrom(t *testing.T) {
+	setup(t)
+	assert.NoError(t, orm.Insert(&Comment{PostID: 1, Body: "first"}, &Comment{PostID: 2, Body: "second"}, &Comment{PostID: 1, Body: "third"}))
+
+	res, err := orm.InsertFrom[ArchivedComment]([]string{"id", "post_id", "body"},
+		orm.Query[Comment]().Select("id", "post_id", "body").Where("post_id", 1).OrderBy("id", orm.ASC))
+	assert.NoError(t, err)
+	affected, err := res.RowsAffected()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+	archived, err := orm.Query[ArchivedComment]().OrderBy("id", orm.ASC).All()
+	assert.NoError(t, err)
+	assert.Equal(t, []ArchivedComment{{ID: 1, PostID: 1, Body: "first"}, {ID: 3, PostID: 1, Body: "third"}}, archived)
+
+	t.Run("inside transaction of query", func(t *testing.T) {
+		err := orm.Transaction(func(tx *orm.Tx) error {
+			if _, err := orm.InsertFrom[ArchivedComment]([]string{"post_id", "body"}, orm.Query[Comment]().WithTx(tx).Select("post_id", "body").Where("id", 2)); err != nil {
+				return err
+			}
+			return fmt.Errorf("rollback")
+		})
+		assert.EqualError(t, err, "rollback")
+		count, err := orm.Query[ArchivedComment]().Count()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+	})
+
+	t.Run("columns are required", func(t *testing.T) {
+		_, err := orm.InsertFrom[ArchivedComment](nil, orm.Query[Comment]())
+		assert.Error(t, err)
+	})
+}
+
+func TestInsertQuery(t *testing.T) {
+	setup(t)
+	res, err := orm.Query[Category]().Table("post_categories").Insert(orm.KV{"post_id": 1, "category_id": 2}, orm.KV{"post_id": 1, "category_id": 3})
+	assert.NoError(t, err)
+	affected, err := res.RowsAffected()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+	var count int
+	assert.NoError(t, orm.GetConnection("default").Connection.QueryRow(`SELECT count(*) FROM post_categories WHERE post_id = 1`).Scan(&count))
+	assert.Equal(t, 2, count)
+
+	_, err = orm.Query[Category]().Table("post_categories").Columns("post_id", "category_id").Values(2, 2).Values(3, 2).Execute()
+	assert.NoError(t, err)
+	assert.NoError(t, orm.GetConnection("default").Connection.QueryRow(`SELECT count(*) FROM post_categories WHERE category_id = 2`).Scan(&count))
+	assert.Equal(t, 3, count)
+
+	t.Run("rows should have same columns", func(t *testing.T) {
+		_, err := orm.Query[Category]().Table("post_categories").Insert(orm.KV{"post_id": 4, "category_id": 1}, orm.KV{"post_id": 5})
+		assert.Error(t, err)
+	})
+}
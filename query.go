@@ -1,15 +1,20 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"reflect"
+	"sort"
 	"strings"
+	"time"
 )
 
 const (
 	queryTypeSELECT = iota + 1
 	queryTypeUPDATE
 	queryTypeDelete
+	queryTypeINSERT
 )
 
 // QueryBuilder is our query builder, almost all methods and functions in GoLobby ORM
@@ -20,22 +25,49 @@ type QueryBuilder[E Entity] struct {
 	where                *whereClause
 	table                string
 	placeholderGenerator func(n int) []string
+	dialect              *Dialect
 
 	// select parts
 	orderBy  *orderByClause
 	groupBy  *GroupBy
+	having   *whereClause
 	selected *selected
 	subQuery *QueryBuilder[E]
 	joins    []*Join
 	limit    *Limit
 	offset   *Offset
 
+	// union, intersect and except parts
+	compounds []*compound[E]
+
+	// common table expressions parts
+	ctes []*cte
+
+	// row locking parts
+	lock     string
+	lockWait string
+
 	// update parts
 	sets [][2]interface{}
 
+	// insert parts
+	insertColumns []string
+	insertValues  [][]interface{}
+	insertFrom    Subquery
+
+	// soft delete and timestamps parts
+	softDeleteColumn string
+	// softDeleteTable is table of the entity, soft delete condition is only added when querying it.
+	softDeleteTable string
+	trashed         int
+	updatedAtColumn string
+	skipTimestamps  bool
+
 	// execution parts
-	db  *sql.DB
-	err error
+	ctx        context.Context
+	tx         *Tx
+	usePrimary bool
+	err        error
 }
 
 type raw struct {
@@ -44,7 +76,8 @@ type raw struct {
 }
 
 // Raw creates a Raw sql query chunk that you can add to several components of QueryBuilder like
-// Wheres.
+// Wheres. Use ? for placeholders, on PostgreSQL they are replaced with numbered placeholders except
+// inside quoted strings, write ?? for a literal ? like the jsonb ? operator.
 func Raw(sql string, args ...interface{}) *raw {
 	return &raw{sql: sql, args: args}
 }
@@ -60,7 +93,11 @@ func (q *QueryBuilder[E]) All() ([]E, error) {
 	if err != nil {
 		return nil, err
 	}
-	rows, err := getSchemaFor(*new(E)).getConnection().query(queryString, args...)
+	exec, err := getSchemaFor(*new(E)).getExecutor(q.tx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := exec.query(q.getContext(), queryString, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -83,7 +120,11 @@ func (q *QueryBuilder[E]) One() (E, error) {
 	if err != nil {
 		return *new(E), err
 	}
-	rows, err := getSchemaFor(*new(E)).getConnection().query(queryString, args...)
+	exec, err := getSchemaFor(*new(E)).getExecutor(q.tx)
+	if err != nil {
+		return *new(E), err
+	}
+	rows, err := exec.query(q.getContext(), queryString, args...)
 	if err != nil {
 		return *new(E), err
 	}
@@ -96,38 +137,95 @@ func (q *QueryBuilder[E]) One() (E, error) {
 }
 
 // Count creates and execute a select query from QueryBuilder and set it's field list of selection
-// to COUNT(id).
+// to COUNT of primary key of the entity.
 func (q *QueryBuilder[E]) Count() (int64, error) {
+	var counter int64
+	if err := q.aggregate(fmt.Sprintf("COUNT(%s)", quoteIdentifier(q.dialect, getSchemaFor(*new(E)).pkName())), &counter); err != nil {
+		return 0, err
+	}
+	return counter, nil
+}
+
+// CountDistinct is like Count but counts distinct values of given column.
+func (q *QueryBuilder[E]) CountDistinct(column string) (int64, error) {
+	var counter int64
+	if err := q.aggregate(fmt.Sprintf("COUNT(DISTINCT %s)", quoteIdentifier(q.dialect, column)), &counter); err != nil {
+		return 0, err
+	}
+	return counter, nil
+}
+
+// Sum executes a select query from given QueryBuilder and returns SUM of given column,
+// it's zero value of T when there is no row.
+func Sum[E Entity, T any](q *QueryBuilder[E], column string) (T, error) {
+	return aggregate[E, T](q, "SUM", column)
+}
+
+// Avg is like Sum but returns AVG of given column.
+func Avg[E Entity, T any](q *QueryBuilder[E], column string) (T, error) {
+	return aggregate[E, T](q, "AVG", column)
+}
+
+// Min is like Sum but returns MIN of given column.
+func Min[E Entity, T any](q *QueryBuilder[E], column string) (T, error) {
+	return aggregate[E, T](q, "MIN", column)
+}
+
+// Max is like Sum but returns MAX of given column.
+func Max[E Entity, T any](q *QueryBuilder[E], column string) (T, error) {
+	return aggregate[E, T](q, "MAX", column)
+}
+
+func aggregate[E Entity, T any](q *QueryBuilder[E], function string, column string) (T, error) {
+	// aggregates are NULL when there is no row so we scan into a pointer.
+	var value *T
+	if err := q.aggregate(fmt.Sprintf("%s(%s)", function, quoteIdentifier(q.dialect, column)), &value); err != nil {
+		return *new(T), err
+	}
+	if value == nil {
+		return *new(T), nil
+	}
+	return *value, nil
+}
+
+// aggregate executes a select query from QueryBuilder with given expression as its
+// field list of selection and scans the result into dest.
+func (q *QueryBuilder[E]) aggregate(expr string, dest any) error {
 	if q.err != nil {
-		return 0, q.err
+		return q.err
+	}
+	if q.compounds != nil {
+		return fmt.Errorf("aggregates are not supported on UNION, INTERSECT or EXCEPT queries")
 	}
-	q.selected = &selected{Columns: []string{"COUNT(id)"}}
+	// the builder can still be used after the aggregate, so its selection is restored.
+	previous, typ := q.selected, q.typ
+	q.selected = &selected{Columns: []string{expr}}
 	q.SetSelect()
 	queryString, args, err := q.ToSql()
+	q.selected, q.typ = previous, typ
 	if err != nil {
-		return 0, err
-	}
-	row := getSchemaFor(*new(E)).getConnection().queryRow(queryString, args...)
-	if row.Err() != nil {
-		return 0, err
+		return err
 	}
-	var counter int64
-	err = row.Scan(&counter)
+	exec, err := getSchemaFor(*new(E)).getExecutor(q.tx)
 	if err != nil {
-		return 0, err
+		return err
 	}
-	return counter, nil
+	row := exec.queryRow(q.getContext(), queryString, args...)
+	if row.Err() != nil {
+		return row.Err()
+	}
+	return row.Scan(dest)
 }
 
-// First is like One but it also do a OrderBy("id", ASC)
+// First is like One but it also do a OrderBy on primary key ascending.
 func (q *QueryBuilder[E]) First() (E, error) {
-	q.OrderBy("id", ASC)
+	q.OrderBy(getSchemaFor(*new(E)).pkName(), ASC)
 	return q.One()
 }
 
-// Latest is like One but it also do a OrderBy("id", DESC)
+// Latest is like One but it also do a OrderBy on primary key descending.
 func (q *QueryBuilder[E]) Latest() (E, error) {
-	q.OrderBy("id", DESC)
+	q.OrderBy(getSchemaFor(*new(E)).pkName(), DESC)
 	return q.One()
 }
 
@@ -137,7 +235,7 @@ func (q *QueryBuilder[E]) WherePK(value interface{}) *QueryBuilder[E] {
 	return q.Where(getSchemaFor(*new(E)).pkName(), value)
 }
 
-// Execute executes QueryBuilder query, remember to use this when you have an Update
+// Execute executes QueryBuilder query, remember to use this when you have an Insert, Update
 // or Delete Query.
 func (q *QueryBuilder[E]) Execute() (sql.Result, error) {
 	if q.err != nil {
@@ -146,18 +244,31 @@ func (q *QueryBuilder[E]) Execute() (sql.Result, error) {
 	if q.typ == queryTypeSELECT {
 		return nil, fmt.Errorf("query type is SELECT")
 	}
+	if q.typ == queryTypeUPDATE {
+		q.touchUpdatedAt()
+	}
 	query, args, err := q.ToSql()
 	if err != nil {
 		return nil, err
 	}
-	return getSchemaFor(*new(E)).getConnection().exec(query, args...)
+	exec, err := getSchemaFor(*new(E)).getExecutor(q.tx)
+	if err != nil {
+		return nil, err
+	}
+	return exec.exec(q.getContext(), query, args...)
 }
 
-// Delete sets QueryBuilder type to be delete and then Executes it.
+// Delete sets QueryBuilder type to be delete and then Executes it, if entity supports soft deletes
+// it will be an update query setting deleted at column instead.
 func (q *QueryBuilder[E]) Delete() (sql.Result, error) {
 	if q.err != nil {
 		return nil, q.err
 	}
+	if q.softDeleteColumn != "" {
+		q.SetUpdate()
+		q.sets = append(q.sets, [2]interface{}{q.softDeleteColumn, time.Now()})
+		return q.Execute()
+	}
 	q.SetDelete()
 	return q.Execute()
 }
@@ -183,15 +294,71 @@ func (q *QueryBuilder[E]) Update(toUpdate map[string]interface{}) (sql.Result, e
 	return q.Execute()
 }
 
-func (d *QueryBuilder[E]) toSqlDelete() (string, []interface{}, error) {
-	base := fmt.Sprintf("DELETE FROM %s", d.table)
+// Insert creates an Insert query from QueryBuilder and executes it into database, each given key values is a row and
+// they all should have the same keys, columns are sorted by name unless they are set using Columns.
+//
+//	orm.Query[Post]().Table("post_categories").Insert(orm.KV{"post_id": 1, "category_id": 2})
+func (q *QueryBuilder[E]) Insert(rows ...map[string]interface{}) (sql.Result, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no rows given to insert")
+	}
+	if q.insertColumns == nil {
+		for column := range rows[0] {
+			q.insertColumns = append(q.insertColumns, column)
+		}
+		sort.Strings(q.insertColumns)
+	}
+	for _, row := range rows {
+		if len(row) != len(q.insertColumns) {
+			return nil, fmt.Errorf("row has %d columns but insert has %d columns", len(row), len(q.insertColumns))
+		}
+		values := make([]interface{}, 0, len(row))
+		for _, column := range q.insertColumns {
+			value, exists := row[column]
+			if !exists {
+				return nil, fmt.Errorf("row has no value for column %s", column)
+			}
+			values = append(values, value)
+		}
+		q.Values(values...)
+	}
+	return q.Execute()
+}
+
+// whereToSql renders where clauses of QueryBuilder plus soft delete condition if there is any.
+func (q *QueryBuilder[E]) whereToSql() (string, []interface{}, error) {
+	var where string
 	var args []interface{}
-	if d.where != nil {
-		d.where.PlaceHolderGenerator = d.placeholderGenerator
-		where, whereArgs, err := d.where.ToSql()
+	if q.where != nil {
+		q.where.PlaceHolderGenerator = q.placeholderGenerator
+		q.where.dialect = q.dialect
+		var err error
+		where, args, err = q.where.ToSql()
 		if err != nil {
 			return "", nil, err
 		}
+	}
+	trashedCond := q.trashedCondition()
+	if trashedCond == "" {
+		return where, args, nil
+	}
+	if where == "" {
+		return trashedCond, args, nil
+	}
+	return fmt.Sprintf("(%s) AND %s", where, trashedCond), args, nil
+}
+
+func (d *QueryBuilder[E]) toSqlDelete() (string, []interface{}, error) {
+	base := fmt.Sprintf("DELETE FROM %s", d.quote(d.table))
+	var args []interface{}
+	where, whereArgs, err := d.whereToSql()
+	if err != nil {
+		return "", nil, err
+	}
+	if where != "" {
 		base += " WHERE " + where
 		args = append(args, whereArgs...)
 	}
@@ -206,8 +373,8 @@ func pop(phs *[]string) string {
 func (u *QueryBuilder[E]) kvString() string {
 	phs := u.placeholderGenerator(len(u.sets))
 	var sets []string
-	for _, pair := range u.sets {
-		sets = append(sets, fmt.Sprintf("%s=%s", pair[0], pop(&phs)))
+	for i, pair := range u.sets {
+		sets = append(sets, fmt.Sprintf("%s=%s", u.quote(fmt.Sprint(pair[0])), phs[i]))
 	}
 	return strings.Join(sets, ",")
 }
@@ -224,32 +391,62 @@ func (u *QueryBuilder[E]) toSqlUpdate() (string, []interface{}, error) {
 	if u.table == "" {
 		return "", nil, fmt.Errorf("table cannot be empty")
 	}
-	base := fmt.Sprintf("UPDATE %s SET %s", u.table, u.kvString())
+	base := fmt.Sprintf("UPDATE %s SET %s", u.quote(u.table), u.kvString())
 	args := u.args()
-	if u.where != nil {
-		u.where.PlaceHolderGenerator = u.placeholderGenerator
-		where, whereArgs, err := u.where.ToSql()
-		if err != nil {
-			return "", nil, err
-		}
+	where, whereArgs, err := u.whereToSql()
+	if err != nil {
+		return "", nil, err
+	}
+	if where != "" {
 		args = append(args, whereArgs...)
 		base += " WHERE " + where
 	}
 	return base, args, nil
 }
+func (q *QueryBuilder[E]) toSqlInsert() (string, []interface{}, error) {
+	if q.table == "" {
+		return "", nil, fmt.Errorf("table cannot be empty")
+	}
+	if len(q.insertColumns) == 0 {
+		return "", nil, fmt.Errorf("insert query has no columns")
+	}
+	stmt := insertStmt{
+		PlaceHolderGenerator: q.placeholderGenerator,
+		Dialect:              q.dialect,
+		Table:                q.table,
+		Columns:              q.insertColumns,
+		Values:               q.insertValues,
+	}
+	if q.insertFrom != nil {
+		sub, args, err := q.insertFrom.subquery(q.placeholderGenerator, q.dialect)
+		if err != nil {
+			return "", nil, err
+		}
+		stmt.Select = &raw{sql: sub, args: args}
+	} else if len(q.insertValues) == 0 {
+		return "", nil, fmt.Errorf("insert query has no values")
+	}
+	query, args := stmt.ToSql()
+	return query, args, nil
+}
+
 func (s *QueryBuilder[E]) toSqlSelect() (string, []interface{}, error) {
 	if s.err != nil {
 		return "", nil, s.err
 	}
-	base := "SELECT"
-	var args []interface{}
+	// with, common table expressions come first so their placeholders come first too.
+	base, args, err := s.withToSql()
+	if err != nil {
+		return "", nil, err
+	}
+	base += "SELECT"
 	// select
 	if s.selected == nil {
 		s.selected = &selected{
 			Columns: []string{"*"},
 		}
 	}
-	base += " " + s.selected.String()
+	base += " " + s.selected.toSql(s.dialect)
 	// from
 	if s.table == "" && s.subQuery == nil {
 		return "", nil, fmt.Errorf("Table name cannot be empty")
@@ -257,11 +454,10 @@ func (s *QueryBuilder[E]) toSqlSelect() (string, []interface{}, error) {
 		return "", nil, fmt.Errorf("cannot have both Table and subquery")
 	}
 	if s.table != "" {
-		base += " " + "FROM " + s.table
+		base += " " + "FROM " + s.quote(s.table)
 	}
 	if s.subQuery != nil {
-		s.subQuery.placeholderGenerator = s.placeholderGenerator
-		subQuery, subArgs, err := s.subQuery.ToSql()
+		subQuery, subArgs, err := s.subQuery.toSqlAsPartOf(s.placeholderGenerator, s.dialect)
 		if err != nil {
 
 			return "", nil, fmt.Errorf("SubQuery: %w", err)
@@ -270,30 +466,54 @@ func (s *QueryBuilder[E]) toSqlSelect() (string, []interface{}, error) {
 		args = append(args, subArgs...)
 	}
 	// Joins
-	if s.joins != nil {
-		for _, join := range s.joins {
-			base += " " + join.String()
+	for _, join := range s.joins {
+		joinSql, joinArgs, err := s.joinToSql(join)
+		if err != nil {
+			return "", nil, err
 		}
+		base += " " + joinSql
+		args = append(args, joinArgs...)
 	}
 	// whereClause
-	if s.where != nil {
-		s.where.PlaceHolderGenerator = s.placeholderGenerator
-		where, whereArgs, err := s.where.ToSql()
+	where, whereArgs, err := s.whereToSql()
+	if err != nil {
+		return "", nil, err
+	}
+	if where != "" {
+		base += " WHERE " + where
+		args = append(args, whereArgs...)
+	}
+
+	// GroupBy
+	if s.groupBy != nil {
+		base += " " + s.groupBy.toSql(s.dialect)
+	}
+
+	// Having
+	if s.having != nil {
+		s.having.PlaceHolderGenerator = s.placeholderGenerator
+		s.having.dialect = s.dialect
+		having, havingArgs, err := s.having.ToSql()
 		if err != nil {
 			return "", nil, err
 		}
-		base += " WHERE " + where
-		args = append(args, whereArgs...)
+		base += " HAVING " + having
+		args = append(args, havingArgs...)
 	}
 
-	// orderByClause
-	if s.orderBy != nil {
-		base += " " + s.orderBy.String()
+	// Compounds, order by, limit and offset after them apply to the combined result.
+	for _, c := range s.compounds {
+		member, memberArgs, err := s.compoundMemberToSql(c.query)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", c.op, err)
+		}
+		base += " " + c.op + " " + member
+		args = append(args, memberArgs...)
 	}
 
-	// GroupBy
-	if s.groupBy != nil {
-		base += " " + s.groupBy.String()
+	// orderByClause
+	if s.orderBy != nil {
+		base += " " + s.orderBy.toSql(s.dialect)
 	}
 
 	// Limit
@@ -306,12 +526,40 @@ func (s *QueryBuilder[E]) toSqlSelect() (string, []interface{}, error) {
 		base += " " + s.offset.String()
 	}
 
+	// Lock
+	if s.lockWait != "" && s.lock == "" {
+		return "", nil, fmt.Errorf("%s needs LockForUpdate or LockForShare", s.lockWait)
+	}
+	if s.lock != "" && s.compounds != nil {
+		return "", nil, fmt.Errorf("locking clauses are not supported on UNION, INTERSECT or EXCEPT queries")
+	}
+	if s.lock != "" && (s.dialect == nil || s.dialect.SupportsRowLocking) {
+		base += " FOR " + s.lock
+		if s.lockWait != "" {
+			base += " " + s.lockWait
+		}
+	}
+
 	return base, args, nil
 }
 
 // ToSql creates sql query from QueryBuilder based on internal fields it would decide what kind
 // of query to build.
 func (q *QueryBuilder[E]) ToSql() (string, []interface{}, error) {
+	if q.err != nil {
+		return "", nil, q.err
+	}
+	if q.placeholderGenerator != nil {
+		generator := q.placeholderGenerator
+		q.placeholderGenerator = sequentialPlaceholders(generator)
+		defer func() { q.placeholderGenerator = generator }()
+	}
+	return q.toSql()
+}
+
+// toSql is like ToSql but continues numbering of placeholders from current state of placeholder generator,
+// it's used for rendering parts of a bigger query like subqueries.
+func (q *QueryBuilder[E]) toSql() (string, []interface{}, error) {
 	if q.err != nil {
 		return "", nil, q.err
 	}
@@ -321,11 +569,59 @@ func (q *QueryBuilder[E]) ToSql() (string, []interface{}, error) {
 		return q.toSqlDelete()
 	} else if q.typ == queryTypeUPDATE {
 		return q.toSqlUpdate()
+	} else if q.typ == queryTypeINSERT {
+		return q.toSqlInsert()
 	} else {
 		return "", nil, fmt.Errorf("no sql type matched")
 	}
 }
 
+// compoundMemberToSql renders a query combined with QueryBuilder using UNION, INTERSECT or EXCEPT.
+func (q *QueryBuilder[E]) compoundMemberToSql(member *QueryBuilder[E]) (string, []interface{}, error) {
+	if member.orderBy != nil || member.limit != nil || member.offset != nil {
+		return "", nil, fmt.Errorf("combined queries cannot have their own order by, limit or offset, add them to the outer query")
+	}
+	if member.compounds != nil {
+		return "", nil, fmt.Errorf("combined queries cannot be combined with other queries themselves")
+	}
+	if member.lock != "" {
+		return "", nil, fmt.Errorf("combined queries cannot lock rows")
+	}
+	member.SetSelect()
+	return member.toSqlAsPartOf(q.placeholderGenerator, q.dialect)
+}
+
+// joinToSql renders a join of QueryBuilder.
+func (q *QueryBuilder[E]) joinToSql(join *Join) (string, []interface{}, error) {
+	var args []interface{}
+	target := q.quote(join.Table)
+	if join.subquery != nil {
+		sub, subArgs, err := join.subquery.subquery(q.placeholderGenerator, q.dialect)
+		if err != nil {
+			return "", nil, fmt.Errorf("join: %w", err)
+		}
+		target = "(" + sub + ")"
+		args = append(args, subArgs...)
+	}
+	if join.alias != "" {
+		target += " AS " + q.quote(join.alias)
+	}
+	base := fmt.Sprintf("%s JOIN %s", join.Type, target)
+	if join.on != nil {
+		join.on.PlaceHolderGenerator = q.placeholderGenerator
+		join.on.dialect = q.dialect
+		on, onArgs, err := join.on.ToSql()
+		if err != nil {
+			return "", nil, fmt.Errorf("join: %w", err)
+		}
+		base += " ON " + on
+		args = append(args, onArgs...)
+	} else if join.On.Lhs != "" {
+		base += " ON " + join.On.toSql(q.dialect)
+	}
+	return base, args, nil
+}
+
 type orderByOrder string
 
 const (
@@ -337,10 +633,10 @@ type orderByClause struct {
 	Columns [][2]string
 }
 
-func (o orderByClause) String() string {
+func (o orderByClause) toSql(dialect *Dialect) string {
 	var tuples []string
 	for _, pair := range o.Columns {
-		tuples = append(tuples, fmt.Sprintf("%s %s", pair[0], pair[1]))
+		tuples = append(tuples, fmt.Sprintf("%s %s", quoteIdentifier(dialect, pair[0]), pair[1]))
 	}
 	return fmt.Sprintf("ORDER BY %s", strings.Join(tuples, ","))
 }
@@ -349,8 +645,8 @@ type GroupBy struct {
 	Columns []string
 }
 
-func (g GroupBy) String() string {
-	return fmt.Sprintf("GROUP BY %s", strings.Join(g.Columns, ","))
+func (g GroupBy) toSql(dialect *Dialect) string {
+	return fmt.Sprintf("GROUP BY %s", strings.Join(quoteIdentifiers(dialect, g.Columns), ","))
 }
 
 type joinType string
@@ -360,7 +656,10 @@ const (
 	JoinTypeLeft  = "LEFT"
 	JoinTypeRight = "RIGHT"
 	JoinTypeFull  = "FULL OUTER"
-	JoinTypeSelf  = "SELF"
+	JoinTypeCross = "CROSS"
+	// Deprecated: SELF is not a join type, to join a table with itself use
+	// one of JoinFunc methods and give the table an alias using JoinClause.As.
+	JoinTypeSelf = "SELF"
 )
 
 type JoinOn struct {
@@ -368,18 +667,72 @@ type JoinOn struct {
 	Rhs string
 }
 
-func (j JoinOn) String() string {
-	return fmt.Sprintf("%s = %s", j.Lhs, j.Rhs)
+func (j JoinOn) toSql(dialect *Dialect) string {
+	return fmt.Sprintf("%s = %s", quoteIdentifier(dialect, j.Lhs), quoteIdentifier(dialect, j.Rhs))
 }
 
 type Join struct {
 	Type  joinType
 	Table string
 	On    JoinOn
+
+	// parts of joins added using JoinFunc methods.
+	alias    string
+	subquery Subquery
+	on       *whereClause
+}
+
+// JoinClause configures conditions and alias of a join added by one of JoinFunc methods of QueryBuilder.
+type JoinClause struct {
+	alias string
+	on    *whereClause
+}
+
+// On adds a condition comparing two columns to the join, joined with previous conditions using AND.
+func (j *JoinClause) On(lhs string, op string, rhs string) *JoinClause {
+	return j.add(nextType_AND, cond{Lhs: lhs, Op: binaryOp(op), Rhs: columnName(rhs)})
+}
+
+// OrOn is like On but joins the condition with previous conditions using OR.
+func (j *JoinClause) OrOn(lhs string, op string, rhs string) *JoinClause {
+	return j.add(nextType_OR, cond{Lhs: lhs, Op: binaryOp(op), Rhs: columnName(rhs)})
+}
+
+// OnValue adds a condition comparing a column to a value to the join, value is passed as an argument
+// of the query, for example OnValue("comments.status", "=", "approved").
+func (j *JoinClause) OnValue(column string, op string, value interface{}) *JoinClause {
+	return j.add(nextType_AND, cond{Lhs: column, Op: binaryOp(op), Rhs: value})
+}
+
+// OrOnValue is like OnValue but joins the condition with previous conditions using OR.
+func (j *JoinClause) OrOnValue(column string, op string, value interface{}) *JoinClause {
+	return j.add(nextType_OR, cond{Lhs: column, Op: binaryOp(op), Rhs: value})
+}
+
+// As sets alias of joined table or subquery, it's required when joining a subquery.
+func (j *JoinClause) As(alias string) *JoinClause {
+	j.alias = alias
+	return j
+}
+
+func (j *JoinClause) add(typ string, c cond) *JoinClause {
+	j.on = appendClause(j.on, typ, &whereClause{cond: c})
+	return j
 }
 
-func (j Join) String() string {
-	return fmt.Sprintf("%s JOIN %s ON %s", j.Type, j.Table, j.On.String())
+// columnName is right hand side of a condition that is a column instead of a value.
+type columnName string
+
+const (
+	compoundUnion     = "UNION"
+	compoundUnionAll  = "UNION ALL"
+	compoundIntersect = "INTERSECT"
+	compoundExcept    = "EXCEPT"
+)
+
+type compound[E Entity] struct {
+	op    string
+	query *QueryBuilder[E]
 }
 
 type Limit struct {
@@ -402,8 +755,8 @@ type selected struct {
 	Columns []string
 }
 
-func (s selected) String() string {
-	return fmt.Sprintf("%s", strings.Join(s.Columns, ","))
+func (s selected) toSql(dialect *Dialect) string {
+	return strings.Join(quoteIdentifiers(dialect, s.Columns), ",")
 }
 
 // OrderBy adds an OrderBy section to QueryBuilder.
@@ -477,56 +830,192 @@ func (q *QueryBuilder[E]) FullOuterJoin(table string, onLhs string, onRhs string
 	return q
 }
 
-// Where Adds a where clause to query, if already have where clause append to it
-// as AndWhere.
-func (q *QueryBuilder[E]) Where(parts ...interface{}) *QueryBuilder[E] {
-	if q.where != nil {
-		return q.addWhere("AND", parts...)
-	}
-	if len(parts) == 1 {
-		if r, isRaw := parts[0].(*raw); isRaw {
-			q.where = &whereClause{raw: r.sql, args: r.args, PlaceHolderGenerator: q.placeholderGenerator}
-			return q
-		} else {
-			q.err = fmt.Errorf("when you have one argument passed to where, it should be *raw")
-			return q
-		}
+// InnerJoinFunc adds an inner join section to QueryBuilder with conditions and alias set by f,
+// conditions can compare columns or compare a column with a value:
+//
+//	q.InnerJoinFunc("comments", func(j *orm.JoinClause) {
+//		j.On("comments.post_id", "=", "posts.id").OnValue("comments.status", "=", "approved")
+//	})
+func (q *QueryBuilder[E]) InnerJoinFunc(table string, f func(j *JoinClause)) *QueryBuilder[E] {
+	return q.addJoin(JoinTypeInner, table, nil, f)
+}
 
-	} else if len(parts) == 2 {
-		if strings.Index(parts[0].(string), " ") == -1 {
-			// Equal mode
-			q.where = &whereClause{cond: cond{Lhs: parts[0].(string), Op: Eq, Rhs: parts[1]}, PlaceHolderGenerator: q.placeholderGenerator}
-		}
-		return q
-	} else if len(parts) == 3 {
-		// operator mode
-		q.where = &whereClause{cond: cond{Lhs: parts[0].(string), Op: binaryOp(parts[1].(string)), Rhs: parts[2]}, PlaceHolderGenerator: q.placeholderGenerator}
-		return q
-	} else if len(parts) > 3 && parts[1].(string) == "IN" {
-		q.where = &whereClause{cond: cond{Lhs: parts[0].(string), Op: binaryOp(parts[1].(string)), Rhs: parts[2:]}, PlaceHolderGenerator: q.placeholderGenerator}
+// JoinFunc is like InnerJoinFunc.
+func (q *QueryBuilder[E]) JoinFunc(table string, f func(j *JoinClause)) *QueryBuilder[E] {
+	return q.InnerJoinFunc(table, f)
+}
+
+// LeftJoinFunc is like InnerJoinFunc but adds a left join.
+func (q *QueryBuilder[E]) LeftJoinFunc(table string, f func(j *JoinClause)) *QueryBuilder[E] {
+	return q.addJoin(JoinTypeLeft, table, nil, f)
+}
+
+// RightJoinFunc is like InnerJoinFunc but adds a right join.
+func (q *QueryBuilder[E]) RightJoinFunc(table string, f func(j *JoinClause)) *QueryBuilder[E] {
+	return q.addJoin(JoinTypeRight, table, nil, f)
+}
+
+// FullOuterJoinFunc is like InnerJoinFunc but adds a full outer join.
+func (q *QueryBuilder[E]) FullOuterJoinFunc(table string, f func(j *JoinClause)) *QueryBuilder[E] {
+	return q.addJoin(JoinTypeFull, table, nil, f)
+}
+
+// CrossJoin adds a cross join section to QueryBuilder.
+func (q *QueryBuilder[E]) CrossJoin(table string) *QueryBuilder[E] {
+	return q.addJoin(JoinTypeCross, table, nil, nil)
+}
+
+// JoinSub adds a join of given type, like JoinTypeLeft, against a subquery to QueryBuilder, subquery can be a
+// *QueryBuilder of any entity or Raw and f should set its alias using As and also conditions of the join
+// unless it's a JoinTypeCross.
+func (q *QueryBuilder[E]) JoinSub(typ string, sub Subquery, f func(j *JoinClause)) *QueryBuilder[E] {
+	return q.addJoin(typ, "", sub, f)
+}
+
+func (q *QueryBuilder[E]) addJoin(typ string, table string, sub Subquery, f func(j *JoinClause)) *QueryBuilder[E] {
+	q.SetSelect()
+	clause := &JoinClause{}
+	if f != nil {
+		f(clause)
+	}
+	if sub != nil && clause.alias == "" {
+		q.err = fmt.Errorf("joined subquery needs an alias")
 		return q
-	} else {
-		q.err = fmt.Errorf("wrong number of arguments passed to Where")
+	}
+	if typ != JoinTypeCross && clause.on == nil {
+		name := table
+		if name == "" {
+			name = clause.alias
+		}
+		q.err = fmt.Errorf("%s join of %s needs at least one condition", typ, name)
 		return q
 	}
+	q.joins = append(q.joins, &Join{Type: joinType(typ), Table: table, alias: clause.alias, subquery: sub, on: clause.on})
+	return q
+}
+
+const (
+	trashedExclude = iota
+	trashedInclude
+	trashedOnly
+)
+
+// setSchema enables soft delete and timestamps behaviour of QueryBuilder if given
+// schema has deleted at and updated at fields.
+func (q *QueryBuilder[E]) setSchema(s *schema) *QueryBuilder[E] {
+	if f := s.deletedAt(); f != nil {
+		q.softDeleteColumn = f.Name
+		q.softDeleteTable = s.Table
+	}
+	if f := s.updatedAt(); f != nil {
+		q.updatedAtColumn = f.Name
+	}
+	return q
+}
+
+func (q *QueryBuilder[E]) trashedCondition() string {
+	if q.softDeleteColumn == "" || q.trashed == trashedInclude {
+		return ""
+	}
+	table, alias := splitTableAlias(q.table)
+	if q.softDeleteTable != "" && table != q.softDeleteTable {
+		return ""
+	}
+	column := q.softDeleteColumn
+	if alias != "" {
+		column = alias + "." + column
+	} else if table != "" {
+		column = table + "." + column
+	}
+	column = q.quote(column)
+	if q.trashed == trashedOnly {
+		return column + " IS NOT NULL"
+	}
+	return column + " IS NULL"
+}
+
+// splitTableAlias splits a table like "posts p" or "posts AS p" into table name and alias.
+func splitTableAlias(table string) (string, string) {
+	parts := strings.Fields(table)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	if len(parts) == 3 && strings.EqualFold(parts[1], "AS") {
+		return parts[0], parts[2]
+	}
+	return table, ""
+}
+
+// WithTrashed makes QueryBuilder include soft deleted rows.
+func (q *QueryBuilder[E]) WithTrashed() *QueryBuilder[E] {
+	q.trashed = trashedInclude
+	return q
+}
+
+// OnlyTrashed makes QueryBuilder only include soft deleted rows.
+func (q *QueryBuilder[E]) OnlyTrashed() *QueryBuilder[E] {
+	q.trashed = trashedOnly
+	return q
+}
+
+// ForceDelete is like Delete but it always removes rows even if entity supports soft deletes.
+func (q *QueryBuilder[E]) ForceDelete() (sql.Result, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	// rows are removed whether they are soft deleted or not.
+	q.trashed = trashedInclude
+	q.SetDelete()
+	return q.Execute()
+}
+
+// Restore restores soft deleted rows matching QueryBuilder by setting their deleted at column to NULL.
+func (q *QueryBuilder[E]) Restore() (sql.Result, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	if q.softDeleteColumn == "" {
+		return nil, fmt.Errorf("%s does not support soft deletes", q.table)
+	}
+	if q.trashed == trashedExclude {
+		q.trashed = trashedOnly
+	}
+	q.SetUpdate()
+	q.sets = append(q.sets, [2]interface{}{q.softDeleteColumn, nil})
+	return q.Execute()
+}
+
+// Where Adds a where clause to query, if already have where clause append to it
+// as AndWhere.
+func (q *QueryBuilder[E]) Where(parts ...interface{}) *QueryBuilder[E] {
+	return q.addWhere(nextType_AND, parts...)
 }
 
 type binaryOp string
 
 const (
-	Eq      = "="
-	GT      = ">"
-	LT      = "<"
-	GE      = ">="
-	LE      = "<="
-	NE      = "!="
-	Between = "BETWEEN"
-	Like    = "LIKE"
-	In      = "IN"
+	Eq         = "="
+	GT         = ">"
+	LT         = "<"
+	GE         = ">="
+	LE         = "<="
+	NE         = "!="
+	Between    = "BETWEEN"
+	NotBetween = "NOT BETWEEN"
+	Like       = "LIKE"
+	NotLike    = "NOT LIKE"
+	ILike      = "ILIKE"
+	In         = "IN"
+	NotIn      = "NOT IN"
+	IsNull     = "IS NULL"
+	IsNotNull  = "IS NOT NULL"
+	Exists     = "EXISTS"
+	NotExists  = "NOT EXISTS"
 )
 
 type cond struct {
 	PlaceHolderGenerator func(n int) []string
+	dialect              *Dialect
 
 	Lhs string
 	Op  binaryOp
@@ -535,23 +1024,93 @@ type cond struct {
 
 func (b cond) ToSql() (string, []interface{}, error) {
 	var phs []string
-	if b.Op == In {
-		rhs, isInterfaceSlice := b.Rhs.([]interface{})
-		if isInterfaceSlice {
-			phs = b.PlaceHolderGenerator(len(rhs))
-			return fmt.Sprintf("%s IN (%s)", b.Lhs, strings.Join(phs, ",")), rhs, nil
-		} else if rawThing, isRaw := b.Rhs.(*raw); isRaw {
-			return fmt.Sprintf("%s IN (%s)", b.Lhs, rawThing.sql), rawThing.args, nil
-		} else {
-			return "", nil, fmt.Errorf("Right hand side of Cond when operator is IN should be either a interface{} slice or *raw")
+	lhs := quoteIdentifier(b.dialect, b.Lhs)
+	switch b.Op {
+	case In, NotIn:
+		if sub, isSubquery := b.Rhs.(Subquery); isSubquery {
+			query, args, err := sub.subquery(b.PlaceHolderGenerator, b.dialect)
+			if err != nil {
+				return "", nil, err
+			}
+			return fmt.Sprintf("%s %s (%s)", lhs, b.Op, query), args, nil
+		}
+		values := flattenValues(b.Rhs)
+		if len(values) == 0 {
+			// IN () is not valid sql, nothing is in an empty list and everything is not.
+			if b.Op == In {
+				return "1 = 0", nil, nil
+			}
+			return "1 = 1", nil, nil
+		}
+		phs = b.PlaceHolderGenerator(len(values))
+		return fmt.Sprintf("%s %s (%s)", lhs, b.Op, strings.Join(phs, ",")), values, nil
+	case Between, NotBetween:
+		values := flattenValues(b.Rhs)
+		if len(values) != 2 {
+			return "", nil, fmt.Errorf("right hand side of %s should have exactly two values but has %d", b.Op, len(values))
+		}
+		phs = b.PlaceHolderGenerator(2)
+		return fmt.Sprintf("%s %s %s AND %s", lhs, b.Op, phs[0], phs[1]), values, nil
+	case IsNull, IsNotNull:
+		return fmt.Sprintf("%s %s", lhs, b.Op), nil, nil
+	case Exists, NotExists:
+		sub, isSubquery := b.Rhs.(Subquery)
+		if !isSubquery {
+			return "", nil, fmt.Errorf("right hand side of %s should be a subquery", b.Op)
+		}
+		query, args, err := sub.subquery(b.PlaceHolderGenerator, b.dialect)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("%s (%s)", b.Op, query), args, nil
+	case ILike:
+		phs = b.PlaceHolderGenerator(1)
+		if b.dialect != nil && b.dialect.SupportsILike {
+			return fmt.Sprintf("%s ILIKE %s", lhs, phs[0]), []interface{}{b.Rhs}, nil
+		}
+		return fmt.Sprintf("LOWER(%s) LIKE LOWER(%s)", lhs, phs[0]), []interface{}{b.Rhs}, nil
+	default:
+		if column, isColumn := b.Rhs.(columnName); isColumn {
+			return fmt.Sprintf("%s %s %s", lhs, b.Op, quoteIdentifier(b.dialect, string(column))), nil, nil
+		}
+		if sub, isSubquery := b.Rhs.(Subquery); isSubquery {
+			// comparing with a scalar subquery, it should return a single row and column.
+			query, args, err := sub.subquery(b.PlaceHolderGenerator, b.dialect)
+			if err != nil {
+				return "", nil, err
+			}
+			return fmt.Sprintf("%s %s (%s)", lhs, b.Op, query), args, nil
 		}
-
-	} else {
 		phs = b.PlaceHolderGenerator(1)
-		return fmt.Sprintf("%s %s %s", b.Lhs, b.Op, pop(&phs)), []interface{}{b.Rhs}, nil
+		return fmt.Sprintf("%s %s %s", lhs, b.Op, pop(&phs)), []interface{}{b.Rhs}, nil
 	}
 }
 
+// flattenValues returns values of a multi value operator like IN, slices
+// are expanded to their elements except []byte which is a single value.
+func flattenValues(rhs interface{}) []interface{} {
+	var values []interface{}
+	var add func(v interface{})
+	add = func(v interface{}) {
+		if vs, isInterfaceSlice := v.([]interface{}); isInterfaceSlice {
+			for _, v := range vs {
+				add(v)
+			}
+			return
+		}
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+			for i := 0; i < rv.Len(); i++ {
+				values = append(values, rv.Index(i).Interface())
+			}
+			return
+		}
+		values = append(values, v)
+	}
+	add(rhs)
+	return values
+}
+
 const (
 	nextType_AND = "AND"
 	nextType_OR  = "OR"
@@ -562,16 +1121,25 @@ type whereClause struct {
 	nextTyp              string
 	next                 *whereClause
 	cond
-	raw  string
-	args []interface{}
+	raw   string
+	args  []interface{}
+	group *whereClause
 }
 
 func (w whereClause) ToSql() (string, []interface{}, error) {
 	var base string
 	var args []interface{}
 	var err error
-	if w.raw != "" {
-		base = w.raw
+	if w.group != nil {
+		w.group.PlaceHolderGenerator = w.PlaceHolderGenerator
+		w.group.dialect = w.dialect
+		base, args, err = w.group.ToSql()
+		if err != nil {
+			return "", nil, err
+		}
+		base = "(" + base + ")"
+	} else if w.raw != "" {
+		base = rawWithPlaceholders(w.raw, w.PlaceHolderGenerator, w.dialect)
 		args = w.args
 	} else {
 		w.cond.PlaceHolderGenerator = w.PlaceHolderGenerator
@@ -584,6 +1152,8 @@ func (w whereClause) ToSql() (string, []interface{}, error) {
 		return base, args, nil
 	}
 	if w.next != nil {
+		w.next.PlaceHolderGenerator = w.PlaceHolderGenerator
+		w.next.dialect = w.dialect
 		next, nextArgs, err := w.next.ToSql()
 		if err != nil {
 			return "", nil, err
@@ -597,8 +1167,71 @@ func (w whereClause) ToSql() (string, []interface{}, error) {
 }
 
 // WhereIn adds a where clause to QueryBuilder using In operator.
+// Values can also be a slice or a subquery, either a *QueryBuilder of any entity or Raw.
 func (q *QueryBuilder[E]) WhereIn(column string, values ...interface{}) *QueryBuilder[E] {
-	return q.Where(append([]interface{}{column, In}, values...)...)
+	return q.appendWhere(nextType_AND, &whereClause{cond: cond{Lhs: column, Op: In, Rhs: inRhs(values)}, PlaceHolderGenerator: q.placeholderGenerator})
+}
+
+// WhereNotIn adds a where clause to QueryBuilder using NOT IN operator, values can be like WhereIn values.
+func (q *QueryBuilder[E]) WhereNotIn(column string, values ...interface{}) *QueryBuilder[E] {
+	return q.appendWhere(nextType_AND, &whereClause{cond: cond{Lhs: column, Op: NotIn, Rhs: inRhs(values)}, PlaceHolderGenerator: q.placeholderGenerator})
+}
+
+// inRhs unwraps a single subquery passed as values of IN operator.
+func inRhs(values []interface{}) interface{} {
+	if len(values) == 1 {
+		if sub, isSubquery := values[0].(Subquery); isSubquery {
+			return sub
+		}
+	}
+	return values
+}
+
+// WhereExists adds a where clause to QueryBuilder checking given subquery has any rows,
+// subquery can be a *QueryBuilder of any entity or Raw.
+func (q *QueryBuilder[E]) WhereExists(sub Subquery) *QueryBuilder[E] {
+	return q.appendWhere(nextType_AND, &whereClause{cond: cond{Op: Exists, Rhs: sub}, PlaceHolderGenerator: q.placeholderGenerator})
+}
+
+// WhereNotExists adds a where clause to QueryBuilder checking given subquery has no rows.
+func (q *QueryBuilder[E]) WhereNotExists(sub Subquery) *QueryBuilder[E] {
+	return q.appendWhere(nextType_AND, &whereClause{cond: cond{Op: NotExists, Rhs: sub}, PlaceHolderGenerator: q.placeholderGenerator})
+}
+
+// WhereNull adds a where clause to QueryBuilder checking column is NULL.
+func (q *QueryBuilder[E]) WhereNull(column string) *QueryBuilder[E] {
+	return q.appendWhere(nextType_AND, &whereClause{cond: cond{Lhs: column, Op: IsNull}, PlaceHolderGenerator: q.placeholderGenerator})
+}
+
+// WhereNotNull adds a where clause to QueryBuilder checking column is not NULL.
+func (q *QueryBuilder[E]) WhereNotNull(column string) *QueryBuilder[E] {
+	return q.appendWhere(nextType_AND, &whereClause{cond: cond{Lhs: column, Op: IsNotNull}, PlaceHolderGenerator: q.placeholderGenerator})
+}
+
+// WhereBetween adds a where clause to QueryBuilder checking column is between low and high, inclusive.
+func (q *QueryBuilder[E]) WhereBetween(column string, low interface{}, high interface{}) *QueryBuilder[E] {
+	return q.appendWhere(nextType_AND, &whereClause{cond: cond{Lhs: column, Op: Between, Rhs: []interface{}{low, high}}, PlaceHolderGenerator: q.placeholderGenerator})
+}
+
+// WhereNotBetween adds a where clause to QueryBuilder checking column is not between low and high.
+func (q *QueryBuilder[E]) WhereNotBetween(column string, low interface{}, high interface{}) *QueryBuilder[E] {
+	return q.appendWhere(nextType_AND, &whereClause{cond: cond{Lhs: column, Op: NotBetween, Rhs: []interface{}{low, high}}, PlaceHolderGenerator: q.placeholderGenerator})
+}
+
+// WhereLike adds a where clause to QueryBuilder using LIKE operator.
+func (q *QueryBuilder[E]) WhereLike(column string, pattern string) *QueryBuilder[E] {
+	return q.appendWhere(nextType_AND, &whereClause{cond: cond{Lhs: column, Op: Like, Rhs: pattern}, PlaceHolderGenerator: q.placeholderGenerator})
+}
+
+// WhereNotLike adds a where clause to QueryBuilder using NOT LIKE operator.
+func (q *QueryBuilder[E]) WhereNotLike(column string, pattern string) *QueryBuilder[E] {
+	return q.appendWhere(nextType_AND, &whereClause{cond: cond{Lhs: column, Op: NotLike, Rhs: pattern}, PlaceHolderGenerator: q.placeholderGenerator})
+}
+
+// WhereILike adds a case-insensitive LIKE where clause to QueryBuilder, it uses ILIKE
+// on databases that support it and compares lowered values on others.
+func (q *QueryBuilder[E]) WhereILike(column string, pattern string) *QueryBuilder[E] {
+	return q.appendWhere(nextType_AND, &whereClause{cond: cond{Lhs: column, Op: ILike, Rhs: pattern}, PlaceHolderGenerator: q.placeholderGenerator})
 }
 
 // AndWhere appends a where clause to query builder as And where clause.
@@ -611,38 +1244,120 @@ func (q *QueryBuilder[E]) OrWhere(parts ...interface{}) *QueryBuilder[E] {
 	return q.addWhere(nextType_OR, parts...)
 }
 
+// WhereGroup adds conditions that f adds to given QueryBuilder as a parenthesised group joined using AND,
+// groups can be nested, for example to have (a OR b) AND c:
+//
+//	q.WhereGroup(func(q *QueryBuilder[E]) { q.Where("a", 1).OrWhere("b", 2) }).Where("c", 3)
+func (q *QueryBuilder[E]) WhereGroup(f func(q *QueryBuilder[E])) *QueryBuilder[E] {
+	return q.addWhereGroup(nextType_AND, f)
+}
+
+// OrWhereGroup is like WhereGroup but joins the group using OR.
+func (q *QueryBuilder[E]) OrWhereGroup(f func(q *QueryBuilder[E])) *QueryBuilder[E] {
+	return q.addWhereGroup(nextType_OR, f)
+}
+
+func (q *QueryBuilder[E]) addWhereGroup(typ string, f func(q *QueryBuilder[E])) *QueryBuilder[E] {
+	group := &QueryBuilder[E]{placeholderGenerator: q.placeholderGenerator, dialect: q.dialect}
+	f(group)
+	if group.err != nil {
+		q.err = group.err
+		return q
+	}
+	if group.where == nil {
+		return q
+	}
+	return q.appendWhere(typ, &whereClause{group: group.where, PlaceHolderGenerator: q.placeholderGenerator})
+}
+
+// appendWhere adds given clause to the end of where clauses of QueryBuilder.
+func (q *QueryBuilder[E]) appendWhere(typ string, clause *whereClause) *QueryBuilder[E] {
+	q.where = appendClause(q.where, typ, clause)
+	return q
+}
+
+// appendClause adds given clause to the end of list of clauses and returns head of the list.
+func appendClause(list *whereClause, typ string, clause *whereClause) *whereClause {
+	if list == nil {
+		return clause
+	}
+	w := list
+	for w.next != nil {
+		w = w.next
+	}
+	w.next = clause
+	w.nextTyp = typ
+	return list
+}
+
+// Having adds a having clause to QueryBuilder, it accepts same arguments as Where
+// and if already has having clause appends to it using AND.
+func (q *QueryBuilder[E]) Having(parts ...interface{}) *QueryBuilder[E] {
+	return q.addHaving(nextType_AND, parts...)
+}
+
+// OrHaving appends a having clause to QueryBuilder using OR.
+func (q *QueryBuilder[E]) OrHaving(parts ...interface{}) *QueryBuilder[E] {
+	return q.addHaving(nextType_OR, parts...)
+}
+
+func (q *QueryBuilder[E]) addHaving(typ string, parts ...interface{}) *QueryBuilder[E] {
+	q.SetSelect()
+	// having conditions are just like where conditions so we let Where parse them.
+	h := &QueryBuilder[E]{placeholderGenerator: q.placeholderGenerator, dialect: q.dialect}
+	h.Where(parts...)
+	if h.err != nil {
+		q.err = h.err
+		return q
+	}
+	if h.where != nil {
+		q.having = appendClause(q.having, typ, h.where)
+	}
+	return q
+}
+
 func (q *QueryBuilder[E]) addWhere(typ string, parts ...interface{}) *QueryBuilder[E] {
-	w := q.where
-	for {
-		if w == nil {
-			break
-		} else if w.next == nil {
-			w.next = &whereClause{PlaceHolderGenerator: q.placeholderGenerator}
-			w.nextTyp = typ
-			w = w.next
-			break
-		} else {
-			w = w.next
-		}
-	}
-	if w == nil {
-		w = &whereClause{PlaceHolderGenerator: q.placeholderGenerator}
+	clause, err := q.parseWhere(parts...)
+	if err != nil {
+		q.err = err
+		return q
 	}
+	return q.appendWhere(typ, clause)
+}
+
+// parseWhere makes a where clause from arguments of Where, it accepts a *raw, a column and a value to
+// compare using equal, a column an operator and a value or a column IN or NOT IN operator and its values.
+func (q *QueryBuilder[E]) parseWhere(parts ...interface{}) (*whereClause, error) {
 	if len(parts) == 1 {
-		w.raw = parts[0].(*raw).sql
-		w.args = parts[0].(*raw).args
-		return q
-	} else if len(parts) == 2 {
+		r, isRaw := parts[0].(*raw)
+		if !isRaw {
+			return nil, fmt.Errorf("when you have one argument passed to where, it should be *raw")
+		}
+		return &whereClause{raw: r.sql, args: r.args, PlaceHolderGenerator: q.placeholderGenerator}, nil
+	}
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("wrong number of arguments passed to Where")
+	}
+	lhs, isString := parts[0].(string)
+	if !isString {
+		return nil, fmt.Errorf("first argument passed to Where should be a column name but it is %T", parts[0])
+	}
+	if len(parts) == 2 {
 		// Equal mode
-		w.cond = cond{Lhs: parts[0].(string), Op: Eq, Rhs: parts[1]}
-		return q
-	} else if len(parts) == 3 {
+		return &whereClause{cond: cond{Lhs: lhs, Op: Eq, Rhs: parts[1]}, PlaceHolderGenerator: q.placeholderGenerator}, nil
+	}
+	op, isString := parts[1].(string)
+	if !isString {
+		return nil, fmt.Errorf("second argument passed to Where should be an operator but it is %T", parts[1])
+	}
+	if len(parts) == 3 {
 		// operator mode
-		w.cond = cond{Lhs: parts[0].(string), Op: binaryOp(parts[1].(string)), Rhs: parts[2]}
-		return q
-	} else {
-		panic("wrong number of arguments passed to Where")
+		return &whereClause{cond: cond{Lhs: lhs, Op: binaryOp(op), Rhs: parts[2]}, PlaceHolderGenerator: q.placeholderGenerator}, nil
 	}
+	if op != In && op != NotIn {
+		return nil, fmt.Errorf("wrong number of arguments passed to Where")
+	}
+	return &whereClause{cond: cond{Lhs: lhs, Op: binaryOp(op), Rhs: parts[2:]}, PlaceHolderGenerator: q.placeholderGenerator}, nil
 }
 
 // Offset adds offset section to query builder.
@@ -659,6 +1374,35 @@ func (q *QueryBuilder[E]) Limit(n int) *QueryBuilder[E] {
 	return q
 }
 
+// LockForUpdate locks selected rows so other transactions cannot change or lock them until current transaction ends,
+// it only makes sense inside a transaction. SQLite3 has no row locks, it locks the whole database when a transaction
+// writes, so locking clauses are left out there.
+func (q *QueryBuilder[E]) LockForUpdate() *QueryBuilder[E] {
+	q.SetSelect()
+	q.lock = "UPDATE"
+	return q
+}
+
+// LockForShare is like LockForUpdate but other transactions can still read and share lock selected rows.
+func (q *QueryBuilder[E]) LockForShare() *QueryBuilder[E] {
+	q.SetSelect()
+	q.lock = "SHARE"
+	return q
+}
+
+// SkipLocked makes a locking select skip rows that are already locked instead of waiting for them,
+// useful for claiming jobs from a queue table.
+func (q *QueryBuilder[E]) SkipLocked() *QueryBuilder[E] {
+	q.lockWait = "SKIP LOCKED"
+	return q
+}
+
+// NoWait makes a locking select fail instead of waiting when a selected row is already locked.
+func (q *QueryBuilder[E]) NoWait() *QueryBuilder[E] {
+	q.lockWait = "NOWAIT"
+	return q
+}
+
 // Table sets table of QueryBuilder.
 func (q *QueryBuilder[E]) Table(t string) *QueryBuilder[E] {
 	q.table = t
@@ -700,6 +1444,114 @@ func (q *QueryBuilder[E]) FromQuery(subQuery *QueryBuilder[E]) *QueryBuilder[E]
 	return q
 }
 
+// Union combines rows of QueryBuilder with rows of given query removing duplicates, both should select same columns.
+// OrderBy, Limit and Offset of QueryBuilder apply to the combined result and given query cannot have its own.
+func (q *QueryBuilder[E]) Union(other *QueryBuilder[E]) *QueryBuilder[E] {
+	return q.addCompound(compoundUnion, other)
+}
+
+// UnionAll is like Union but keeps duplicate rows.
+func (q *QueryBuilder[E]) UnionAll(other *QueryBuilder[E]) *QueryBuilder[E] {
+	return q.addCompound(compoundUnionAll, other)
+}
+
+// Intersect is like Union but only keeps rows that are in both queries.
+func (q *QueryBuilder[E]) Intersect(other *QueryBuilder[E]) *QueryBuilder[E] {
+	return q.addCompound(compoundIntersect, other)
+}
+
+// Except is like Union but only keeps rows of QueryBuilder that are not in given query.
+func (q *QueryBuilder[E]) Except(other *QueryBuilder[E]) *QueryBuilder[E] {
+	return q.addCompound(compoundExcept, other)
+}
+
+func (q *QueryBuilder[E]) addCompound(op string, other *QueryBuilder[E]) *QueryBuilder[E] {
+	q.SetSelect()
+	q.compounds = append(q.compounds, &compound[E]{op: op, query: other})
+	return q
+}
+
+// Subquery is a query that can be rendered as part of another query, it's implemented by *QueryBuilder
+// of any entity and by Raw so subqueries don't need to be of the same entity as the outer query.
+type Subquery interface {
+	// subquery renders the query continuing placeholders of generator.
+	subquery(generator func(n int) []string, dialect *Dialect) (string, []interface{}, error)
+}
+
+func (q *QueryBuilder[E]) subquery(generator func(n int) []string, dialect *Dialect) (string, []interface{}, error) {
+	q.SetSelect()
+	return q.toSqlAsPartOf(generator, dialect)
+}
+
+// toSqlAsPartOf renders QueryBuilder as a part of an outer query using its placeholder generator and dialect,
+// they are restored afterwards so QueryBuilder can still be used on its own.
+func (q *QueryBuilder[E]) toSqlAsPartOf(generator func(n int) []string, dialect *Dialect) (string, []interface{}, error) {
+	placeholderGenerator, ownDialect := q.placeholderGenerator, q.dialect
+	q.placeholderGenerator, q.dialect = generator, dialect
+	defer func() { q.placeholderGenerator, q.dialect = placeholderGenerator, ownDialect }()
+	return q.toSql()
+}
+
+func (r *raw) subquery(generator func(n int) []string, dialect *Dialect) (string, []interface{}, error) {
+	return rawWithPlaceholders(r.sql, generator, dialect), r.args, nil
+}
+
+// cte is a common table expression, recursive is combined with query using UNION ALL when it's set.
+type cte struct {
+	name      string
+	query     Subquery
+	recursive Subquery
+}
+
+// With adds a common table expression named name to QueryBuilder so it can be used in from, joins and
+// subqueries of the query like a table, name can also contain column names like "totals(user_id, total)".
+func (q *QueryBuilder[E]) With(name string, query Subquery) *QueryBuilder[E] {
+	q.SetSelect()
+	q.ctes = append(q.ctes, &cte{name: name, query: query})
+	return q
+}
+
+// WithRecursive adds a recursive common table expression named name to QueryBuilder, rows of anchor are
+// combined using UNION ALL with rows of recursive which can refer to name to get rows of previous step.
+//
+//	q.Table("tree").WithRecursive("tree",
+//		orm.Query[Category]().Where("id", 1),
+//		orm.Query[Category]().Select("categories.*").Join("tree", "tree.id", "categories.parent_id"))
+func (q *QueryBuilder[E]) WithRecursive(name string, anchor Subquery, recursive Subquery) *QueryBuilder[E] {
+	q.SetSelect()
+	q.ctes = append(q.ctes, &cte{name: name, query: anchor, recursive: recursive})
+	return q
+}
+
+// withToSql renders WITH clause of QueryBuilder followed by a space, or nothing if it has no common table expression.
+func (q *QueryBuilder[E]) withToSql() (string, []interface{}, error) {
+	if q.ctes == nil {
+		return "", nil, nil
+	}
+	keyword := "WITH"
+	var ctes []string
+	var args []interface{}
+	for _, c := range q.ctes {
+		query, queryArgs, err := c.query.subquery(q.placeholderGenerator, q.dialect)
+		if err != nil {
+			return "", nil, fmt.Errorf("with %s: %w", c.name, err)
+		}
+		args = append(args, queryArgs...)
+		if c.recursive != nil {
+			// RECURSIVE applies to the whole WITH clause, not only this expression.
+			keyword = "WITH RECURSIVE"
+			recursive, recursiveArgs, err := c.recursive.subquery(q.placeholderGenerator, q.dialect)
+			if err != nil {
+				return "", nil, fmt.Errorf("with %s: %w", c.name, err)
+			}
+			query += " UNION ALL " + recursive
+			args = append(args, recursiveArgs...)
+		}
+		ctes = append(ctes, fmt.Sprintf("%s AS (%s)", q.quote(c.name), query))
+	}
+	return keyword + " " + strings.Join(ctes, ", ") + " ", args, nil
+}
+
 func (q *QueryBuilder[E]) SetUpdate() *QueryBuilder[E] {
 	q.typ = queryTypeUPDATE
 	return q
@@ -716,8 +1568,32 @@ func (q *QueryBuilder[E]) Sets(tuples ...[2]interface{}) *QueryBuilder[E] {
 	q.sets = append(q.sets, tuples...)
 	return q
 }
+func (q *QueryBuilder[E]) SetInsert() *QueryBuilder[E] {
+	q.typ = queryTypeINSERT
+	return q
+}
+
+// Columns sets columns of insert query, values of each row are inserted into them in order.
+func (q *QueryBuilder[E]) Columns(columns ...string) *QueryBuilder[E] {
+	q.SetInsert()
+	q.insertColumns = columns
+	return q
+}
+
+// Values adds a row to insert query, it should have a value for each column set using Columns.
+func (q *QueryBuilder[E]) Values(values ...interface{}) *QueryBuilder[E] {
+	q.SetInsert()
+	if len(values) != len(q.insertColumns) {
+		q.err = fmt.Errorf("row has %d values but insert has %d columns", len(values), len(q.insertColumns))
+		return q
+	}
+	q.insertValues = append(q.insertValues, values)
+	return q
+}
+
 func (q *QueryBuilder[E]) SetDialect(dialect *Dialect) *QueryBuilder[E] {
 	q.placeholderGenerator = dialect.PlaceHolderGenerator
+	q.dialect = dialect
 	return q
 }
 func (q *QueryBuilder[E]) SetDelete() *QueryBuilder[E] {
@@ -725,6 +1601,61 @@ func (q *QueryBuilder[E]) SetDelete() *QueryBuilder[E] {
 	return q
 }
 
+// WithoutTimestamps stops QueryBuilder from setting updated at column in update queries.
+func (q *QueryBuilder[E]) WithoutTimestamps() *QueryBuilder[E] {
+	q.skipTimestamps = true
+	return q
+}
+
+// touchUpdatedAt adds updated at column to sets of update query unless it's already there.
+func (q *QueryBuilder[E]) touchUpdatedAt() {
+	if q.updatedAtColumn == "" || q.skipTimestamps || shouldSkipTimestamps(q.getContext()) {
+		return
+	}
+	for _, pair := range q.sets {
+		if pair[0] == q.updatedAtColumn {
+			return
+		}
+	}
+	q.sets = append(q.sets, [2]interface{}{q.updatedAtColumn, time.Now()})
+}
+
+// WithTx makes QueryBuilder execute its query inside given transaction.
+func (q *QueryBuilder[E]) WithTx(tx *Tx) *QueryBuilder[E] {
+	q.tx = tx
+	return q
+}
+
+// WithContext sets context that QueryBuilder uses for executing its query.
+func (q *QueryBuilder[E]) WithContext(ctx context.Context) *QueryBuilder[E] {
+	q.ctx = ctx
+	return q
+}
+
+// UsePrimary forces QueryBuilder to read from primary database even when connection has replicas.
+func (q *QueryBuilder[E]) UsePrimary() *QueryBuilder[E] {
+	q.usePrimary = true
+	return q
+}
+
+func (q *QueryBuilder[E]) getContext() context.Context {
+	ctx := context.Background()
+	if q.ctx != nil {
+		ctx = q.ctx
+	} else if q.tx != nil {
+		ctx = q.tx.ctx
+	}
+	if q.usePrimary {
+		ctx = UsePrimary(ctx)
+	}
+	return ctx
+}
+
+// quote quotes given table or column name using dialect of QueryBuilder.
+func (q *QueryBuilder[E]) quote(name string) string {
+	return quoteIdentifier(q.dialect, name)
+}
+
 func NewQueryBuilder[E Entity]() *QueryBuilder[E] {
 	return &QueryBuilder[E]{}
 }
@@ -35,7 +35,7 @@ func TestSelect(t *testing.T) {
 		str, args, err := s.ToSql()
 		assert.NoError(t, err)
 		assert.EqualValues(t, []interface{}{10, 10, "Amirreza", 11}, args)
-		assert.Equal(t, "SELECT * FROM users WHERE age = ? AND age < ? AND name = ? OR age > ?", str)
+		assert.Equal(t, "SELECT * FROM `users` WHERE `age` = ? AND `age` < ? AND `name` = ? OR `age` > ?", str)
 	})
 	t.Run("select with order by", func(t *testing.T) {
 		s := NewQueryBuilder[Dummy]().Table("users").OrderBy("created_at", ASC).OrderBy("updated_at", DESC)
@@ -45,6 +45,21 @@ func TestSelect(t *testing.T) {
 		assert.Equal(t, "SELECT * FROM users ORDER BY created_at ASC,updated_at DESC", str)
 	})
 
+	t.Run("select with group by, having and order by", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().SetDialect(Dialects.PostgreSQL).Table("orders").
+			Select("user_id", "SUM(amount)").
+			Where("status", "paid").
+			OrderBy("user_id", ASC).
+			GroupBy("user_id").
+			Having("SUM(amount)", ">", 100).
+			OrHaving(Raw("COUNT(id) > ?", 10)).
+			Limit(5)
+		str, args, err := s.ToSql()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{"paid", 100, 10}, args)
+		assert.Equal(t, `SELECT "user_id",SUM(amount) FROM "orders" WHERE "status" = $1 GROUP BY "user_id" HAVING SUM(amount) > $2 OR COUNT(id) > $3 ORDER BY "user_id" ASC LIMIT 5`, str)
+	})
+
 	t.Run("select with group by", func(t *testing.T) {
 		s := NewQueryBuilder[Dummy]().Table("users").GroupBy("created_at", "updated_at")
 		str, args, err := s.ToSql()
@@ -94,7 +109,7 @@ func TestSelect(t *testing.T) {
 		sql, args, err := s.ToSql()
 		assert.NoError(t, err)
 		assert.EqualValues(t, []interface{}{10}, args)
-		assert.Equal(t, `SELECT * FROM (SELECT * FROM users WHERE age < ? )`, sql)
+		assert.Equal(t, "SELECT * FROM (SELECT * FROM `users` WHERE `age` < ? )", sql)
 
 	})
 
@@ -133,7 +148,7 @@ func TestSelect(t *testing.T) {
 
 		assert.NoError(t, err)
 		assert.EqualValues(t, []interface{}{1, 10}, args)
-		assert.Equal(t, `SELECT * FROM users WHERE id = ? AND age < ?`, sql)
+		assert.Equal(t, "SELECT * FROM `users` WHERE id = ? AND age < ?", sql)
 	})
 	t.Run("no sql type matched", func(t *testing.T) {
 		sql, args, err := NewQueryBuilder[Dummy]().ToSql()
@@ -153,7 +168,7 @@ func TestSelect(t *testing.T) {
 
 		assert.NoError(t, err)
 		assert.EqualValues(t, []interface{}{10}, args)
-		assert.Equal(t, `SELECT * FROM users WHERE id IN (SELECT user_id FROM user_books WHERE book_id = ?)`, sql)
+		assert.Equal(t, "SELECT * FROM `users` WHERE `id` IN (SELECT user_id FROM user_books WHERE book_id = ?)", sql)
 	})
 	t.Run("where in", func(t *testing.T) {
 		sql, args, err :=
@@ -166,27 +181,459 @@ func TestSelect(t *testing.T) {
 
 		assert.NoError(t, err)
 		assert.EqualValues(t, []interface{}{1, 2, 3, 4, 5, 6}, args)
-		assert.Equal(t, `SELECT * FROM users WHERE id IN (?,?,?,?,?,?)`, sql)
+		assert.Equal(t, "SELECT * FROM `users` WHERE `id` IN (?,?,?,?,?,?)", sql)
 
 	})
 }
+
+func TestWhereOperators(t *testing.T) {
+	t.Run("null checks, not in and between", func(t *testing.T) {
+		sql, args, err := NewQueryBuilder[Dummy]().
+			SetDialect(Dialects.PostgreSQL).
+			Table("users").
+			WhereNull("deleted_at").
+			WhereNotNull("email").
+			WhereNotIn("id", []int64{1, 2, 3}).
+			WhereBetween("age", 18, 30).
+			WhereNotBetween("score", 0, 10).
+			WhereNotLike("name", "a%").
+			SetSelect().
+			ToSql()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{int64(1), int64(2), int64(3), 18, 30, 0, 10, "a%"}, args)
+		assert.Equal(t, `SELECT * FROM "users" WHERE "deleted_at" IS NULL AND "email" IS NOT NULL AND "id" NOT IN ($1,$2,$3) AND "age" BETWEEN $4 AND $5 AND "score" NOT BETWEEN $6 AND $7 AND "name" NOT LIKE $8`, sql)
+	})
+	t.Run("typed slices are expanded", func(t *testing.T) {
+		sql, args, err := NewQueryBuilder[Dummy]().
+			SetDialect(Dialects.MySQL).
+			Table("users").
+			WhereIn("id", []int{1, 2}).
+			Where("name", In, []string{"a", "b"}).
+			SetSelect().
+			ToSql()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{1, 2, "a", "b"}, args)
+		assert.Equal(t, "SELECT * FROM `users` WHERE `id` IN (?,?) AND `name` IN (?,?)", sql)
+	})
+	t.Run("variadic in after other conditions", func(t *testing.T) {
+		sql, args, err := NewQueryBuilder[Dummy]().
+			SetDialect(Dialects.MySQL).
+			Table("users").
+			Where("a", 1).
+			Where("id", NotIn, 1, 2, 3).
+			OrWhere("name", In, "a", "b").
+			SetSelect().
+			ToSql()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{1, 1, 2, 3, "a", "b"}, args)
+		assert.Equal(t, "SELECT * FROM `users` WHERE `a` = ? AND `id` NOT IN (?,?,?) OR `name` IN (?,?)", sql)
+	})
+	t.Run("wrong number of arguments", func(t *testing.T) {
+		_, _, err := NewQueryBuilder[Dummy]().Table("users").Where("a", 1).OrWhere("age", ">", 1, 2).SetSelect().ToSql()
+		assert.Error(t, err)
+		_, _, err = NewQueryBuilder[Dummy]().Table("users").Where("a", 1).AndWhere().SetSelect().ToSql()
+		assert.Error(t, err)
+	})
+	t.Run("empty in", func(t *testing.T) {
+		sql, args, err := NewQueryBuilder[Dummy]().SetDialect(Dialects.MySQL).Table("users").WhereIn("id", []int{}).WhereNotIn("id").SetSelect().ToSql()
+		assert.NoError(t, err)
+		assert.Empty(t, args)
+		assert.Equal(t, "SELECT * FROM `users` WHERE 1 = 0 AND 1 = 1", sql)
+	})
+	t.Run("between needs two values", func(t *testing.T) {
+		_, _, err := NewQueryBuilder[Dummy]().SetDialect(Dialects.MySQL).Table("users").Where("age", Between, 10).SetSelect().ToSql()
+		assert.Error(t, err)
+	})
+	t.Run("case-insensitive like", func(t *testing.T) {
+		sql, _, err := NewQueryBuilder[Dummy]().SetDialect(Dialects.PostgreSQL).Table("users").WhereILike("name", "am%").SetSelect().ToSql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT * FROM "users" WHERE "name" ILIKE $1`, sql)
+		sql, _, err = NewQueryBuilder[Dummy]().SetDialect(Dialects.MySQL).Table("users").WhereILike("name", "am%").SetSelect().ToSql()
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT * FROM `users` WHERE LOWER(`name`) LIKE LOWER(?)", sql)
+	})
+}
+
+func TestWhereGroup(t *testing.T) {
+	t.Run("group joined with and", func(t *testing.T) {
+		sql, args, err := NewQueryBuilder[Dummy]().
+			SetDialect(Dialects.MySQL).
+			Table("users").
+			WhereGroup(func(q *QueryBuilder[Dummy]) {
+				q.Where("age", 10).OrWhere("age", 11)
+			}).
+			AndWhere("name", "Amirreza").
+			SetSelect().
+			ToSql()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{10, 11, "Amirreza"}, args)
+		assert.Equal(t, "SELECT * FROM `users` WHERE (`age` = ? OR `age` = ?) AND `name` = ?", sql)
+	})
+	t.Run("nested groups with postgres placeholders", func(t *testing.T) {
+		sql, args, err := NewQueryBuilder[Dummy]().
+			SetDialect(Dialects.PostgreSQL).
+			Table("users").
+			Where("name", "Amirreza").
+			OrWhereGroup(func(q *QueryBuilder[Dummy]) {
+				q.Where("age", ">", 10).WhereGroup(func(q *QueryBuilder[Dummy]) {
+					q.Where("city", "Tehran").OrWhere(Raw("city = ? OR city = ?", "Shiraz", "Tabriz"))
+				})
+			}).
+			SetSelect().
+			ToSql()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{"Amirreza", 10, "Tehran", "Shiraz", "Tabriz"}, args)
+		assert.Equal(t, `SELECT * FROM "users" WHERE "name" = $1 OR ("age" > $2 AND ("city" = $3 OR city = $4 OR city = $5))`, sql)
+	})
+	t.Run("group starting with or where", func(t *testing.T) {
+		sql, args, err := NewQueryBuilder[Dummy]().
+			SetDialect(Dialects.MySQL).
+			Table("users").
+			Where("name", "Amirreza").
+			WhereGroup(func(q *QueryBuilder[Dummy]) {
+				q.OrWhere("age", 10).OrWhere("age", 11)
+			}).
+			SetSelect().
+			ToSql()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{"Amirreza", 10, 11}, args)
+		assert.Equal(t, "SELECT * FROM `users` WHERE `name` = ? AND (`age` = ? OR `age` = ?)", sql)
+	})
+	t.Run("empty group is ignored", func(t *testing.T) {
+		sql, _, err := NewQueryBuilder[Dummy]().Table("users").WhereGroup(func(q *QueryBuilder[Dummy]) {}).SetSelect().ToSql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT * FROM users`, sql)
+	})
+	t.Run("postgres placeholders in update", func(t *testing.T) {
+		sql, args, err := NewQueryBuilder[Dummy]().
+			SetDialect(Dialects.PostgreSQL).
+			Table("users").
+			Set("name", "amirreza").
+			Set("age", 18).
+			Where("id", 1).
+			ToSql()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{"amirreza", 18, 1}, args)
+		assert.Equal(t, `UPDATE "users" SET "name"=$1,"age"=$2 WHERE "id" = $3`, sql)
+	})
+}
+
+func TestRawPlaceholders(t *testing.T) {
+	t.Run("quoted literals and escaped operators are kept on postgres", func(t *testing.T) {
+		sql, args, err := NewQueryBuilder[Dummy]().
+			SetDialect(Dialects.PostgreSQL).
+			Table("users").
+			Where("id", 1).
+			AndWhere(Raw(`title <> 'why?' AND "what?" = ? AND data ?? 'admin' AND tags ??| array['a'] AND age > ?`, "x", 18)).
+			SetSelect().
+			ToSql()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{1, "x", 18}, args)
+		assert.Equal(t, `SELECT * FROM "users" WHERE "id" = $1 AND title <> 'why?' AND "what?" = $2 AND data ? 'admin' AND tags ?| array['a'] AND age > $3`, sql)
+	})
+	t.Run("raw is left as it is on dialects that use ?", func(t *testing.T) {
+		sql, _, err := NewQueryBuilder[Dummy]().
+			SetDialect(Dialects.MySQL).
+			Table("users").
+			Where(Raw(`title <> 'why?' AND age > ?`, 18)).
+			SetSelect().
+			ToSql()
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT * FROM `users` WHERE title <> 'why?' AND age > ?", sql)
+	})
+}
+
+func TestQuoteIdentifiers(t *testing.T) {
+	t.Run("reserved words and qualified names", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().SetDialect(Dialects.MySQL).Table("order").
+			Select("order.*", "group", "COUNT(user)").
+			Join("user", "user.id", "order.user_id").
+			Where("order.group", 1).
+			SetSelect()
+		sql, _, err := s.ToSql()
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT `order`.*,`group`,COUNT(user) FROM `order` INNER JOIN `user` ON `user`.`id` = `order`.`user_id` WHERE `order`.`group` = ?", sql)
+	})
+	t.Run("mixed case postgres identifiers", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().SetDialect(Dialects.PostgreSQL).Table("Users").Select("userName").Where("userName", "x").SetSelect()
+		sql, _, err := s.ToSql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT "userName" FROM "Users" WHERE "userName" = $1`, sql)
+	})
+	t.Run("quote characters are escaped", func(t *testing.T) {
+		assert.Equal(t, "`a``b`", mysqlQuoteIdentifier("a`b"))
+		assert.Equal(t, `"a""b"`, standardQuoteIdentifier(`a"b`))
+	})
+}
+
+func TestCompound(t *testing.T) {
+	t.Run("union with outer order by and limit", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().SetDialect(Dialects.MySQL).Table("posts").Select("id").Where("user_id", 1).
+			Union(NewQueryBuilder[Dummy]().Table("comments").Select("post_id").Where("user_id", 2)).
+			OrderBy("id", DESC).
+			Limit(10)
+		sql, args, err := s.ToSql()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{1, 2}, args)
+		assert.Equal(t, "SELECT `id` FROM `posts` WHERE `user_id` = ? UNION SELECT `post_id` FROM `comments` WHERE `user_id` = ? ORDER BY `id` DESC LIMIT 10", sql)
+	})
+	t.Run("postgres placeholders continue in combined queries", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().SetDialect(Dialects.PostgreSQL).Table("posts").Where("user_id", 1).
+			UnionAll(NewQueryBuilder[Dummy]().Table("posts").Where("user_id", 2)).
+			Intersect(NewQueryBuilder[Dummy]().Table("posts").WhereIn("id", 3, 4)).
+			Except(NewQueryBuilder[Dummy]().Table("posts").Where("id", 5))
+		sql, args, err := s.ToSql()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{1, 2, 3, 4, 5}, args)
+		assert.Equal(t, `SELECT * FROM "posts" WHERE "user_id" = $1 UNION ALL SELECT * FROM "posts" WHERE "user_id" = $2 INTERSECT SELECT * FROM "posts" WHERE "id" IN ($3,$4) EXCEPT SELECT * FROM "posts" WHERE "id" = $5`, sql)
+	})
+	t.Run("combined query cannot have limit", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().Table("posts").Union(NewQueryBuilder[Dummy]().Table("posts").Limit(1))
+		_, _, err := s.ToSql()
+		assert.Error(t, err)
+	})
+}
+
+func TestWith(t *testing.T) {
+	t.Run("ctes come before select with their placeholders", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().SetDialect(Dialects.PostgreSQL).
+			With("paid", NewQueryBuilder[Dummy]().Table("orders").Select("user_id", "SUM(amount) AS total").Where("status", "paid").GroupBy("user_id")).
+			With("active", Raw("SELECT id FROM users WHERE active = ?", true)).
+			Table("paid").
+			Join("active", "active.id", "paid.user_id").
+			Where("total", ">", 100)
+		sql, args, err := s.ToSql()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{"paid", true, 100}, args)
+		assert.Equal(t, `WITH "paid" AS (SELECT "user_id",SUM(amount) AS total FROM "orders" WHERE "status" = $1 GROUP BY "user_id"), "active" AS (SELECT id FROM users WHERE active = $2) SELECT * FROM "paid" INNER JOIN "active" ON "active"."id" = "paid"."user_id" WHERE "total" > $3`, sql)
+	})
+	t.Run("recursive", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().SetDialect(Dialects.MySQL).
+			WithRecursive("tree",
+				NewQueryBuilder[Dummy]().Table("categories").Where("id", 1),
+				NewQueryBuilder[Dummy]().Table("categories").Select("categories.*").Join("tree", "tree.id", "categories.parent_id")).
+			Table("tree")
+		sql, args, err := s.ToSql()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{1}, args)
+		assert.Equal(t, "WITH RECURSIVE `tree` AS (SELECT * FROM `categories` WHERE `id` = ? UNION ALL SELECT `categories`.* FROM `categories` INNER JOIN `tree` ON `tree`.`id` = `categories`.`parent_id`) SELECT * FROM `tree`", sql)
+	})
+}
+
+func TestWhereSubquery(t *testing.T) {
+	t.Run("where in with a query builder", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().SetDialect(Dialects.PostgreSQL).Table("posts").
+			Where("published", true).
+			WhereIn("id", NewQueryBuilder[Invoice]().Table("post_categories").Select("post_id").Where("category_id", 1)).
+			WhereNotIn("user_id", NewQueryBuilder[Dummy]().Table("bans").Select("user_id").Where("until", ">", 10)).
+			SetSelect()
+		sql, args, err := s.ToSql()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{true, 1, 10}, args)
+		assert.Equal(t, `SELECT * FROM "posts" WHERE "published" = $1 AND "id" IN (SELECT "post_id" FROM "post_categories" WHERE "category_id" = $2) AND "user_id" NOT IN (SELECT "user_id" FROM "bans" WHERE "until" > $3)`, sql)
+	})
+	t.Run("exists and not exists", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().SetDialect(Dialects.PostgreSQL).Table("users").
+			WhereExists(NewQueryBuilder[Dummy]().Table("orders").Select("1").Where(Raw("orders.user_id = users.id")).Where("total", ">", 100)).
+			WhereNotExists(Raw("SELECT 1 FROM bans WHERE bans.user_id = users.id AND until > ?", 10)).
+			SetSelect()
+		sql, args, err := s.ToSql()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{100, 10}, args)
+		assert.Equal(t, `SELECT * FROM "users" WHERE EXISTS (SELECT 1 FROM "orders" WHERE orders.user_id = users.id AND "total" > $1) AND NOT EXISTS (SELECT 1 FROM bans WHERE bans.user_id = users.id AND until > $2)`, sql)
+	})
+	t.Run("scalar subquery comparison", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().SetDialect(Dialects.MySQL).Table("products").
+			Where("category_id", 2).
+			Where("price", ">", NewQueryBuilder[Dummy]().Table("products").Select("AVG(price)").Where("category_id", 2)).
+			SetSelect()
+		sql, args, err := s.ToSql()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{2, 2}, args)
+		assert.Equal(t, "SELECT * FROM `products` WHERE `category_id` = ? AND `price` > (SELECT AVG(price) FROM `products` WHERE `category_id` = ?)", sql)
+	})
+	t.Run("query builder can be used on its own after being a subquery", func(t *testing.T) {
+		inner := NewQueryBuilder[Dummy]().SetDialect(Dialects.PostgreSQL).Table("users").Select("id").Where("active", true)
+		outers := []*QueryBuilder[Dummy]{
+			NewQueryBuilder[Dummy]().SetDialect(Dialects.PostgreSQL).Table("orders").Where("total", ">", 10).WhereIn("user_id", inner),
+			NewQueryBuilder[Dummy]().SetDialect(Dialects.PostgreSQL).Table("orders").Where("total", ">", 10).WhereExists(inner),
+			NewQueryBuilder[Dummy]().SetDialect(Dialects.PostgreSQL).With("active", inner).Table("active").Where("id", ">", 10),
+			NewQueryBuilder[Dummy]().SetDialect(Dialects.PostgreSQL).Table("admins").Select("id").Where("level", 10).Union(inner),
+			NewQueryBuilder[Dummy]().SetDialect(Dialects.PostgreSQL).Where("id", ">", 10).FromQuery(inner),
+		}
+		for _, outer := range outers {
+			_, _, err := outer.SetSelect().ToSql()
+			assert.NoError(t, err)
+			sql, args, err := inner.ToSql()
+			assert.NoError(t, err)
+			assert.EqualValues(t, []interface{}{true}, args)
+			assert.Equal(t, `SELECT "id" FROM "users" WHERE "active" = $1`, sql)
+		}
+	})
+}
+
+func TestJoinFunc(t *testing.T) {
+	t.Run("multiple conditions with values and alias", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().SetDialect(Dialects.PostgreSQL).Table("posts").
+			Select("posts.*").
+			LeftJoinFunc("comments", func(j *JoinClause) {
+				j.As("c").On("c.post_id", "=", "posts.id").OnValue("c.status", "=", "approved").OrOnValue("c.pinned", "=", true)
+			}).
+			InnerJoinFunc("users", func(j *JoinClause) {
+				j.On("users.id", "=", "posts.user_id").OrOn("users.id", "=", "posts.editor_id")
+			}).
+			Where("posts.published", true)
+		sql, args, err := s.ToSql()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{"approved", true, true}, args)
+		assert.Equal(t, `SELECT "posts".* FROM "posts" LEFT JOIN "comments" AS "c" ON "c"."post_id" = "posts"."id" AND "c"."status" = $1 OR "c"."pinned" = $2 INNER JOIN "users" ON "users"."id" = "posts"."user_id" OR "users"."id" = "posts"."editor_id" WHERE "posts"."published" = $3`, sql)
+	})
+	t.Run("subquery join", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().SetDialect(Dialects.MySQL).Table("users").
+			JoinSub(JoinTypeLeft, NewQueryBuilder[Invoice]().Table("orders").Select("user_id", "COUNT(id) AS total").Where("status", "paid").GroupBy("user_id"), func(j *JoinClause) {
+				j.As("o").On("o.user_id", "=", "users.id")
+			}).
+			CrossJoin("settings").
+			Where("users.active", true)
+		sql, args, err := s.ToSql()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{"paid", true}, args)
+		assert.Equal(t, "SELECT * FROM `users` LEFT JOIN (SELECT `user_id`,COUNT(id) AS total FROM `orders` WHERE `status` = ? GROUP BY `user_id`) AS `o` ON `o`.`user_id` = `users`.`id` CROSS JOIN `settings` WHERE `users`.`active` = ?", sql)
+	})
+	t.Run("subquery join needs alias", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().Table("users").JoinSub(JoinTypeInner, Raw("SELECT 1"), func(j *JoinClause) {
+			j.On("a", "=", "b")
+		})
+		_, _, err := s.ToSql()
+		assert.Error(t, err)
+	})
+	t.Run("join needs conditions", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().Table("users").LeftJoinFunc("posts", func(j *JoinClause) {})
+		_, _, err := s.ToSql()
+		assert.Error(t, err)
+	})
+}
+
+func TestLock(t *testing.T) {
+	t.Run("for update skip locked", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().SetDialect(Dialects.PostgreSQL).Table("jobs").Where("status", "pending").OrderBy("id", ASC).Limit(1).LockForUpdate().SkipLocked()
+		sql, _, err := s.ToSql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT * FROM "jobs" WHERE "status" = $1 ORDER BY "id" ASC LIMIT 1 FOR UPDATE SKIP LOCKED`, sql)
+	})
+	t.Run("for share nowait", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().SetDialect(Dialects.MySQL).Table("accounts").Where("id", 1).LockForShare().NoWait()
+		sql, _, err := s.ToSql()
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT * FROM `accounts` WHERE `id` = ? FOR SHARE NOWAIT", sql)
+	})
+	t.Run("sqlite has no row locks", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().SetDialect(Dialects.SQLite3).Table("accounts").LockForUpdate().NoWait()
+		sql, _, err := s.ToSql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT * FROM "accounts"`, sql)
+	})
+	t.Run("skip locked needs a lock", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().SetDialect(Dialects.PostgreSQL).Table("jobs").SkipLocked().SetSelect()
+		_, _, err := s.ToSql()
+		assert.Error(t, err)
+	})
+	t.Run("combined queries cannot lock", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().SetDialect(Dialects.PostgreSQL).Table("jobs").
+			Union(NewQueryBuilder[Dummy]().Table("archived_jobs")).
+			LockForUpdate()
+		_, _, err := s.ToSql()
+		assert.Error(t, err)
+		s = NewQueryBuilder[Dummy]().SetDialect(Dialects.PostgreSQL).Table("jobs").
+			Union(NewQueryBuilder[Dummy]().Table("archived_jobs").LockForShare())
+		_, _, err = s.ToSql()
+		assert.Error(t, err)
+	})
+}
+
+func TestSoftDeleteCondition(t *testing.T) {
+	t.Run("excludes trashed rows by default", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().SetDialect(Dialects.MySQL).Table("users").Where("age", 10).OrWhere("age", 11).SetSelect()
+		s.softDeleteColumn = "deleted_at"
+		sql, args, err := s.ToSql()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{10, 11}, args)
+		assert.Equal(t, "SELECT * FROM `users` WHERE (`age` = ? OR `age` = ?) AND `users`.`deleted_at` IS NULL", sql)
+	})
+	t.Run("only trashed", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().Table("users").OnlyTrashed().SetSelect()
+		s.softDeleteColumn = "deleted_at"
+		sql, _, err := s.ToSql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT * FROM users WHERE users.deleted_at IS NOT NULL`, sql)
+	})
+	t.Run("with trashed", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().Table("users").WithTrashed().SetSelect()
+		s.softDeleteColumn = "deleted_at"
+		sql, _, err := s.ToSql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT * FROM users`, sql)
+	})
+	t.Run("aliased table", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().SetDialect(Dialects.PostgreSQL).Table("users u").Where("u.age", 10).SetSelect()
+		s.softDeleteColumn, s.softDeleteTable = "deleted_at", "users"
+		sql, _, err := s.ToSql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT * FROM users u WHERE ("u"."age" = $1) AND "u"."deleted_at" IS NULL`, sql)
+	})
+	t.Run("other tables are not filtered", func(t *testing.T) {
+		s := NewQueryBuilder[Dummy]().Table("user_roles").SetSelect()
+		s.softDeleteColumn, s.softDeleteTable = "deleted_at", "users"
+		sql, _, err := s.ToSql()
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT * FROM user_roles`, sql)
+	})
+}
+
 func TestUpdate(t *testing.T) {
 	t.Run("update no whereClause", func(t *testing.T) {
 		u := NewQueryBuilder[Dummy]().Table("users").Set("name", "amirreza").SetDialect(Dialects.MySQL)
 		sql, args, err := u.ToSql()
 		assert.NoError(t, err)
-		assert.Equal(t, `UPDATE users SET name=?`, sql)
+		assert.Equal(t, "UPDATE `users` SET `name`=?", sql)
 		assert.Equal(t, []interface{}{"amirreza"}, args)
 	})
 	t.Run("update with whereClause", func(t *testing.T) {
 		u := NewQueryBuilder[Dummy]().Table("users").Set("name", "amirreza").Where("age", "<", 18).SetDialect(Dialects.MySQL)
 		sql, args, err := u.ToSql()
 		assert.NoError(t, err)
-		assert.Equal(t, `UPDATE users SET name=? WHERE age < ?`, sql)
+		assert.Equal(t, "UPDATE `users` SET `name`=? WHERE `age` < ?", sql)
 		assert.Equal(t, []interface{}{"amirreza", 18}, args)
 
 	})
 }
+func TestInsertQuery(t *testing.T) {
+	t.Run("rows of values", func(t *testing.T) {
+		i := NewQueryBuilder[Dummy]().Table("logs").SetDialect(Dialects.PostgreSQL).Columns("level", "message").
+			Values("info", "started").Values("error", "failed")
+		sql, args, err := i.ToSql()
+		assert.NoError(t, err)
+		assert.Equal(t, `INSERT INTO "logs" ("level","message") VALUES ($1,$2),($3,$4)`, sql)
+		assert.Equal(t, []interface{}{"info", "started", "error", "failed"}, args)
+	})
+	t.Run("from subquery", func(t *testing.T) {
+		i := NewQueryBuilder[Dummy]().Table("archived_users").SetDialect(Dialects.PostgreSQL).Columns("id", "name")
+		i.insertFrom = NewQueryBuilder[Dummy]().Table("users").Select("id", "name").Where("active", false)
+		sql, args, err := i.ToSql()
+		assert.NoError(t, err)
+		assert.Equal(t, `INSERT INTO "archived_users" ("id","name") SELECT "id","name" FROM "users" WHERE "active" = $1`, sql)
+		assert.Equal(t, []interface{}{false}, args)
+	})
+	t.Run("row should have a value for each column", func(t *testing.T) {
+		_, _, err := NewQueryBuilder[Dummy]().Table("logs").Columns("level", "message").Values("info").ToSql()
+		assert.Error(t, err)
+	})
+	t.Run("values are required", func(t *testing.T) {
+		_, _, err := NewQueryBuilder[Dummy]().Table("logs").Columns("level").ToSql()
+		assert.Error(t, err)
+	})
+}
+
 func TestDelete(t *testing.T) {
 	t.Run("delete without whereClause", func(t *testing.T) {
 		d := NewQueryBuilder[Dummy]().Table("users").SetDelete()
@@ -199,7 +646,7 @@ func TestDelete(t *testing.T) {
 		d := NewQueryBuilder[Dummy]().Table("users").SetDialect(Dialects.MySQL).Where("created_at", ">", "2012-01-10").SetDelete()
 		sql, args, err := d.ToSql()
 		assert.NoError(t, err)
-		assert.Equal(t, `DELETE FROM users WHERE created_at > ?`, sql)
+		assert.Equal(t, "DELETE FROM `users` WHERE `created_at` > ?", sql)
 		assert.EqualValues(t, []interface{}{"2012-01-10"}, args)
 	})
 }
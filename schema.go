@@ -76,6 +76,74 @@ func (s *schema) Columns(withPK bool) []string {
 	return cols
 }
 
+// columnNames is like Columns but names are never prefixed with table name.
+func (s *schema) columnNames(withPK bool) []string {
+	var cols []string
+	for _, field := range s.fields {
+		if field.Virtual || (!withPK && field.IsPK) {
+			continue
+		}
+		cols = append(cols, field.Name)
+	}
+	return cols
+}
+
+// writableFields returns fields that are written in inserts and updates, generated fields are filled by database.
+func (s *schema) writableFields(withPK bool) []*field {
+	var fields []*field
+	for _, f := range s.fields {
+		if f.Virtual || f.Generated || (!withPK && f.IsPK) {
+			continue
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// writableValuesOf returns values of writableFields of obj in the same order.
+func (s *schema) writableValuesOf(obj Entity, withPK bool) []interface{} {
+	values := genericValuesOf(obj, withPK)
+	var writable []interface{}
+	i := 0
+	for _, f := range s.fields {
+		if f.Virtual || (!withPK && f.IsPK) {
+			continue
+		}
+		if !f.Generated {
+			writable = append(writable, values[i])
+		}
+		i++
+	}
+	return writable
+}
+
+func (s *schema) generatedFields() []*field {
+	var fields []*field
+	for _, f := range s.fields {
+		if f.Generated && !f.Virtual {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+func fieldNames(fields []*field) []string {
+	var names []string
+	for _, f := range fields {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func (s *schema) pkField() *field {
+	for _, field := range s.fields {
+		if field.IsPK {
+			return field
+		}
+	}
+	return nil
+}
+
 func (s *schema) pkName() string {
 	for _, field := range s.fields {
 		if field.IsPK {
@@ -281,6 +349,17 @@ func (s *schema) getSQLDB() *sql.DB {
 	return s.getConnection().Connection
 }
 
+// getExecutor returns given transaction if there is one otherwise schema connection.
+func (s *schema) getExecutor(tx *Tx) (executor, error) {
+	if tx == nil {
+		return s.getConnection(), nil
+	}
+	if tx.conn != s.getConnection() {
+		return nil, fmt.Errorf("transaction is on connection %s but %s uses connection %s", tx.conn.Name, s.Table, s.getConnection().Name)
+	}
+	return tx, nil
+}
+
 func (s *schema) getConnection() *connection {
 	if len(globalConnections) > 1 && (s.Connection == "" || s.Table == "") {
 		panic("need table and Connection name when having more than 1 Connection registered")
@@ -1,6 +1,7 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
 )
 
@@ -9,3 +10,15 @@ type Timestamps struct {
 	UpdatedAt sql.NullTime
 	DeletedAt sql.NullTime
 }
+
+// WithoutTimestamps returns a context that stops ORM from setting created at and updated at
+// fields in queries executed with it, it's useful for data migrations that must
+// preserve timestamps.
+func WithoutTimestamps(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKeyWithoutTimestamps, true)
+}
+
+func shouldSkipTimestamps(ctx context.Context) bool {
+	skip, _ := ctx.Value(contextKeyWithoutTimestamps).(bool)
+	return skip
+}
@@ -0,0 +1,217 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Tx is an in progress database transaction on one of your connections,
+// everything you do through it is either committed or rolled back together.
+// A Tx can also be nested in another one, in that case it's backed by a savepoint.
+type Tx struct {
+	conn *connection
+	tx   *sql.Tx
+	ctx  context.Context
+	done bool
+
+	// nested transaction parts
+	parent     *Tx
+	savepoint  string
+	savepoints int
+}
+
+// Begin starts a new transaction on the default connection.
+func Begin() (*Tx, error) {
+	return BeginContext(context.Background())
+}
+
+// BeginContext is like Begin but given context is used for the whole transaction,
+// if it's canceled transaction will be rolled back.
+func BeginContext(ctx context.Context) (*Tx, error) {
+	c, err := defaultConnection()
+	if err != nil {
+		return nil, err
+	}
+	return c.BeginContext(ctx)
+}
+
+// Transaction runs f inside a transaction on the default connection, if f returns an error
+// or panics transaction will be rolled back otherwise it will be committed.
+func Transaction(f func(tx *Tx) error) error {
+	return TransactionContext(context.Background(), f)
+}
+
+// TransactionContext is like Transaction but given context is used for the whole transaction.
+func TransactionContext(ctx context.Context, f func(tx *Tx) error) error {
+	c, err := defaultConnection()
+	if err != nil {
+		return err
+	}
+	return c.TransactionContext(ctx, f)
+}
+
+// Begin starts a new transaction on the connection.
+func (c *connection) Begin() (*Tx, error) {
+	return c.BeginContext(context.Background())
+}
+
+// BeginContext is like Begin but given context is used for the whole transaction.
+func (c *connection) BeginContext(ctx context.Context) (*Tx, error) {
+	globalLogger.Debugf("BEGIN")
+	tx, err := c.Connection.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{conn: c, tx: tx, ctx: ctx}, nil
+}
+
+// Transaction runs f inside a transaction on the connection, if f returns an error
+// or panics transaction will be rolled back otherwise it will be committed.
+func (c *connection) Transaction(f func(tx *Tx) error) error {
+	return c.TransactionContext(context.Background(), f)
+}
+
+// TransactionContext is like Transaction but given context is used for the whole transaction.
+func (c *connection) TransactionContext(ctx context.Context, f func(tx *Tx) error) error {
+	tx, err := c.BeginContext(ctx)
+	if err != nil {
+		return err
+	}
+	return tx.run(f)
+}
+
+// Begin starts a nested transaction inside tx using a savepoint, committing it releases
+// the savepoint and rolling it back only undoes what happened inside it.
+func (tx *Tx) Begin() (*Tx, error) {
+	if tx.done {
+		return nil, fmt.Errorf("transaction is already finished")
+	}
+	root := tx
+	for root.parent != nil {
+		root = root.parent
+	}
+	root.savepoints++
+	name := fmt.Sprintf("sp_%d", root.savepoints)
+	if _, err := tx.exec(tx.ctx, tx.conn.Dialect.Savepoint(name)); err != nil {
+		return nil, err
+	}
+	return &Tx{conn: tx.conn, tx: tx.tx, ctx: tx.ctx, parent: tx, savepoint: name}, nil
+}
+
+// Transaction runs f inside a nested transaction, if f returns an error or panics
+// only changes made inside f will be rolled back and tx can continue.
+func (tx *Tx) Transaction(f func(tx *Tx) error) error {
+	nested, err := tx.Begin()
+	if err != nil {
+		return err
+	}
+	return nested.run(f)
+}
+
+func (tx *Tx) run(f func(tx *Tx) error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+	if err = f(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("%w, rollback failed: %v", err, rollbackErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// Context returns context of the transaction.
+func (tx *Tx) Context() context.Context {
+	return tx.ctx
+}
+
+// Commit commits the transaction.
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return fmt.Errorf("transaction is already finished")
+	}
+	tx.done = true
+	if tx.savepoint != "" {
+		_, err := tx.exec(tx.ctx, tx.conn.Dialect.ReleaseSavepoint(tx.savepoint))
+		return err
+	}
+	globalLogger.Debugf("COMMIT")
+	return tx.tx.Commit()
+}
+
+// Rollback aborts the transaction, it's a no-op if transaction is already finished.
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return nil
+	}
+	tx.done = true
+	if tx.savepoint != "" {
+		_, err := tx.exec(tx.ctx, tx.conn.Dialect.RollbackToSavepoint(tx.savepoint))
+		return err
+	}
+	globalLogger.Debugf("ROLLBACK")
+	return tx.tx.Rollback()
+}
+
+// Insert is like Insert but inside the transaction.
+func (tx *Tx) Insert(objs ...Entity) error {
+	return insert(tx.ctx, tx, objs...)
+}
+
+// Upsert is like Upsert but inside the transaction.
+func (tx *Tx) Upsert(objs ...Entity) error {
+	return NewUpsert(objs...).WithTx(tx).Execute()
+}
+
+// Save is like Save but inside the transaction.
+func (tx *Tx) Save(obj Entity) error {
+	return save(tx.ctx, tx, obj)
+}
+
+// Update is like Update but inside the transaction.
+func (tx *Tx) Update(obj Entity) error {
+	return update(tx.ctx, tx, obj)
+}
+
+// Delete is like Delete but inside the transaction.
+func (tx *Tx) Delete(obj Entity) error {
+	return del(tx.ctx, tx, obj)
+}
+
+// ForceDelete is like ForceDelete but inside the transaction.
+func (tx *Tx) ForceDelete(obj Entity) error {
+	return forceDelete(tx.ctx, tx, obj)
+}
+
+// Restore is like Restore but inside the transaction.
+func (tx *Tx) Restore(obj Entity) error {
+	return restore(tx.ctx, tx, obj)
+}
+
+// Add is like Add but inside the transaction.
+func (tx *Tx) Add(to Entity, items ...Entity) error {
+	return add(tx.ctx, tx, to, items...)
+}
+
+func (tx *Tx) exec(ctx context.Context, q string, args ...any) (sql.Result, error) {
+	globalLogger.Debugf(q)
+	globalLogger.Debugf("%v", args)
+	return tx.tx.ExecContext(ctx, q, args...)
+}
+
+func (tx *Tx) query(ctx context.Context, q string, args ...any) (*sql.Rows, error) {
+	globalLogger.Debugf(q)
+	globalLogger.Debugf("%v", args)
+	return tx.tx.QueryContext(ctx, q, args...)
+}
+
+func (tx *Tx) queryRow(ctx context.Context, q string, args ...any) *sql.Row {
+	globalLogger.Debugf(q)
+	globalLogger.Debugf("%v", args)
+	return tx.tx.QueryRowContext(ctx, q, args...)
+}
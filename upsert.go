@@ -0,0 +1,192 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Upserter inserts entities and updates the ones that conflict with existing rows, use NewUpsert to create one.
+type Upserter struct {
+	objs      []Entity
+	target    []string
+	update    []string
+	doNothing bool
+
+	ctx context.Context
+	tx  *Tx
+}
+
+// Upsert inserts given entities and when one conflicts with an existing row on its primary key, updates all columns
+// of the row except created at, use NewUpsert to choose conflict columns or columns to update.
+// Primary keys of entities are not set after upsert since databases don't report them for updated rows.
+func Upsert(objs ...Entity) error {
+	return NewUpsert(objs...).Execute()
+}
+
+// UpsertContext is like Upsert but uses given context for executing query.
+func UpsertContext(ctx context.Context, objs ...Entity) error {
+	return NewUpsert(objs...).WithContext(ctx).Execute()
+}
+
+// NewUpsert creates an Upserter for given entities, all of them should be of the same type.
+//
+//	orm.NewUpsert(users...).OnConflict("email").Update("name").Execute()
+func NewUpsert(objs ...Entity) *Upserter {
+	return &Upserter{objs: objs}
+}
+
+// OnConflict sets columns that conflicting rows have same values in, they should have a unique index,
+// it's primary key by default. MySQL checks all unique indexes so it ignores these columns.
+func (u *Upserter) OnConflict(columns ...string) *Upserter {
+	u.target = columns
+	return u
+}
+
+// Update sets columns of conflicting rows that are updated with inserted values, updated at column is always added.
+func (u *Upserter) Update(columns ...string) *Upserter {
+	u.update = columns
+	u.doNothing = false
+	return u
+}
+
+// DoNothing leaves conflicting rows as they are.
+func (u *Upserter) DoNothing() *Upserter {
+	u.doNothing = true
+	u.update = nil
+	return u
+}
+
+// WithTx makes Upserter execute its query inside given transaction.
+func (u *Upserter) WithTx(tx *Tx) *Upserter {
+	u.tx = tx
+	return u
+}
+
+// WithContext sets context that Upserter uses for executing its query.
+func (u *Upserter) WithContext(ctx context.Context) *Upserter {
+	u.ctx = ctx
+	return u
+}
+
+func (u *Upserter) getContext() context.Context {
+	if u.ctx != nil {
+		return u.ctx
+	}
+	if u.tx != nil {
+		return u.tx.ctx
+	}
+	return context.Background()
+}
+
+// Execute inserts entities of Upserter, like Insert they are split into chunks that fit in placeholders limit of
+// the dialect and chunks are executed in a transaction.
+func (u *Upserter) Execute() error {
+	if len(u.objs) == 0 {
+		return nil
+	}
+	ctx := u.getContext()
+	s := getSchemaFor(u.objs[0])
+	dialect := s.getDialect()
+	if dialect.UpsertClause == nil {
+		return fmt.Errorf("dialect %s does not support upsert", dialect.DriverName)
+	}
+	// entities with primary key set are inserted with it so it can be the conflict target.
+	withPK := !isZero(s.getPK(u.objs[0]))
+	for _, obj := range u.objs[1:] {
+		if isZero(s.getPK(obj)) == withPK {
+			return fmt.Errorf("either all or none of upserted entities should have primary key set")
+		}
+	}
+	target := u.target
+	if len(target) == 0 {
+		if !withPK {
+			// primary key is not inserted so it can never conflict, every upsert would insert new rows.
+			return fmt.Errorf("upserted entities have no primary key set, use OnConflict to set columns that conflicts are detected on")
+		}
+		target = []string{s.pkName()}
+	}
+
+	for _, obj := range u.objs {
+		if !shouldSkipTimestamps(ctx) {
+			if f := s.createdAt(); f != nil {
+				genericSet(obj, f.Name, sql.NullTime{Time: time.Now(), Valid: true})
+			}
+			if f := s.updatedAt(); f != nil {
+				genericSet(obj, f.Name, sql.NullTime{Time: time.Now(), Valid: true})
+			}
+		}
+	}
+
+	columns := fieldNames(s.writableFields(withPK))
+	upsert := &upsertClause{Target: target, Update: u.columnsToUpdate(ctx, s, columns, target)}
+	chunks := chunkEntities(u.objs, len(columns), dialect.MaxPlaceholders)
+	if len(chunks) > 1 && u.tx == nil {
+		return s.getConnection().TransactionContext(ctx, func(tx *Tx) error {
+			return upsertChunks(ctx, tx, s, withPK, columns, upsert, chunks)
+		})
+	}
+	return upsertChunks(ctx, u.tx, s, withPK, columns, upsert, chunks)
+}
+
+func upsertChunks(ctx context.Context, tx *Tx, s *schema, withPK bool, columns []string, upsert *upsertClause, chunks [][]Entity) error {
+	exec, err := s.getExecutor(tx)
+	if err != nil {
+		return err
+	}
+	dialect := s.getDialect()
+	for _, chunk := range chunks {
+		var values [][]interface{}
+		for _, obj := range chunk {
+			values = append(values, s.writableValuesOf(obj, withPK))
+		}
+		q, args := insertStmt{
+			PlaceHolderGenerator: dialect.PlaceHolderGenerator,
+			Dialect:              dialect,
+			Table:                s.getTable(),
+			Columns:              columns,
+			Values:               values,
+			Upsert:               upsert,
+		}.ToSql()
+		if _, err := exec.exec(ctx, q, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columnsToUpdate returns columns of conflicting rows that should be updated, it's every inserted column
+// except primary key, conflict target and created at unless Update is used.
+func (u *Upserter) columnsToUpdate(ctx context.Context, s *schema, columns []string, target []string) []string {
+	if u.doNothing {
+		return nil
+	}
+	if len(u.update) > 0 {
+		update := u.update
+		if f := s.updatedAt(); f != nil && !shouldSkipTimestamps(ctx) && !contains(update, f.Name) {
+			update = append(update[:len(update):len(update)], f.Name)
+		}
+		return update
+	}
+	var update []string
+	for _, column := range columns {
+		if column == s.pkName() || contains(target, column) {
+			continue
+		}
+		if f := s.createdAt(); f != nil && f.Name == column {
+			continue
+		}
+		update = append(update, column)
+	}
+	return update
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package orm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ValidationError is returned by SetupConnection when ConnectionConfig.Validate is set and
+// registered entities don't match the database, it contains every mismatch that was found.
+type ValidationError struct {
+	Connection string
+	Problems   []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("entities of connection %s don't match the database:\n  - %s", e.Connection, strings.Join(e.Problems, "\n  - "))
+}
+
+// validator checks schemas of a connection against its database, columns of each
+// table are loaded once and problems are collected instead of failing at the first one.
+type validator struct {
+	ctx      context.Context
+	conn     *connection
+	columns  map[string]map[string]*Column
+	problems []string
+	reported map[string]bool
+}
+
+func (c *connection) validate(ctx context.Context) error {
+	if c.Dialect.ListColumns == nil {
+		return fmt.Errorf("dialect %s does not support listing columns", c.Dialect.DriverName)
+	}
+	v := &validator{ctx: ctx, conn: c, columns: map[string]map[string]*Column{}, reported: map[string]bool{}}
+	var tables []string
+	for table := range c.Schemas {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		if err := v.validateSchema(c.Schemas[table]); err != nil {
+			return err
+		}
+	}
+	if len(v.problems) > 0 {
+		return &ValidationError{Connection: c.Name, Problems: v.problems}
+	}
+	return nil
+}
+
+// report records a problem, each problem is reported once even if multiple entities face it.
+func (v *validator) report(format string, args ...any) {
+	problem := fmt.Sprintf(format, args...)
+	if v.reported[problem] {
+		return
+	}
+	v.reported[problem] = true
+	v.problems = append(v.problems, problem)
+}
+
+// columnsOf returns columns of given table by their name, nil means table does not exist.
+func (v *validator) columnsOf(table string) (map[string]*Column, error) {
+	if columns, loaded := v.columns[table]; loaded {
+		return columns, nil
+	}
+	list, err := v.conn.Dialect.ListColumns(v.ctx, v.conn.Connection, table)
+	if err != nil {
+		return nil, fmt.Errorf("listing columns of %s: %w", table, err)
+	}
+	var columns map[string]*Column
+	if len(list) > 0 {
+		columns = map[string]*Column{}
+		for _, c := range list {
+			columns[c.Name] = c
+		}
+	}
+	v.columns[table] = columns
+	return columns, nil
+}
+
+func (v *validator) validateSchema(s *schema) error {
+	columns, err := v.columnsOf(s.Table)
+	if err != nil {
+		return err
+	}
+	if columns == nil {
+		v.report("table %s does not exist", s.Table)
+	} else {
+		for _, f := range s.fields {
+			if f.Virtual {
+				continue
+			}
+			c, exists := columns[f.Name]
+			if !exists {
+				v.report("column %s.%s does not exist", s.Table, f.Name)
+				continue
+			}
+			if !isCompatibleKind(kindOf(f.Type), kindOfSQLType(c.Type)) {
+				v.report("column %s.%s has type %s which is not compatible with %s", s.Table, f.Name, c.Type, f.Type)
+			}
+		}
+	}
+
+	var relations []string
+	for name := range s.relations {
+		relations = append(relations, name)
+	}
+	sort.Strings(relations)
+	for _, name := range relations {
+		var err error
+		switch c := s.relations[name].(type) {
+		case HasManyConfig:
+			err = v.expectColumn(s.Table, c.PropertyTable, c.PropertyForeignKey)
+		case HasOneConfig:
+			err = v.expectColumn(s.Table, c.PropertyTable, c.PropertyForeignKey)
+		case BelongsToConfig:
+			if err = v.expectColumn(s.Table, s.Table, c.LocalForeignKey); err == nil {
+				err = v.expectColumn(s.Table, c.OwnerTable, c.ForeignColumnName)
+			}
+		case BelongsToManyConfig:
+			if err = v.expectColumn(s.Table, c.IntermediateTable, c.IntermediateOwnerID); err == nil {
+				err = v.expectColumn(s.Table, c.IntermediateTable, c.IntermediatePropertyID)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expectColumn records a problem if column that a relation of entity table depends on does not exist.
+func (v *validator) expectColumn(entity string, table string, column string) error {
+	columns, err := v.columnsOf(table)
+	if err != nil {
+		return err
+	}
+	if columns == nil {
+		v.report("relation of %s: table %s does not exist", entity, table)
+		return nil
+	}
+	if _, exists := columns[column]; !exists {
+		v.report("relation of %s: column %s.%s does not exist", entity, table, column)
+	}
+	return nil
+}
+
+// kindOfSQLType guesses kind of column from its SQL type as database reports it.
+func kindOfSQLType(sqlType string) columnKind {
+	t := strings.ToLower(sqlType)
+	switch {
+	case t == "", t == "point", strings.HasPrefix(t, "interval"):
+		return columnKindUnknown
+	case strings.HasPrefix(t, "tinyint(1)"), strings.HasPrefix(t, "bool"):
+		return columnKindBool
+	case strings.Contains(t, "smallint"), strings.Contains(t, "tinyint"):
+		return columnKindSmallInt
+	case strings.Contains(t, "bigint"), strings.Contains(t, "serial"):
+		return columnKindBigInt
+	case strings.Contains(t, "int"):
+		return columnKindInt
+	case strings.Contains(t, "char"), strings.Contains(t, "text"), strings.Contains(t, "clob"),
+		strings.HasPrefix(t, "enum"), strings.HasPrefix(t, "set"), strings.HasPrefix(t, "json"), t == "uuid":
+		return columnKindString
+	case strings.Contains(t, "real"), strings.Contains(t, "floa"), strings.Contains(t, "doub"),
+		strings.HasPrefix(t, "numeric"), strings.HasPrefix(t, "decimal"):
+		return columnKindFloat
+	case strings.Contains(t, "date"), strings.Contains(t, "time"):
+		return columnKindTime
+	case strings.Contains(t, "blob"), strings.Contains(t, "binary"), t == "bytea":
+		return columnKindBytes
+	}
+	return columnKindUnknown
+}
+
+// isCompatibleKind reports whether values of a column of kind db can be scanned into a field of kind field,
+// unknown kinds are always considered compatible since we cannot reason about them.
+func isCompatibleKind(field columnKind, db columnKind) bool {
+	if field == columnKindUnknown || db == columnKindUnknown || field == db {
+		return true
+	}
+	switch field {
+	case columnKindSmallInt, columnKindInt, columnKindBigInt, columnKindBool:
+		return isIntegerKind(db) || db == columnKindBool
+	case columnKindFloat:
+		return isIntegerKind(db)
+	case columnKindBytes:
+		return db == columnKindString
+	}
+	return false
+}